@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/ratelimit"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type batchRequest struct {
+	Ceps []string `json:"ceps"`
+}
+
+// batchResult is the answer for one CEP of a batch: the temperature Service B
+// answered on success, or the status and message POST /temperature would
+// have answered otherwise.
+type batchResult struct {
+	Cep         string       `json:"cep"`
+	Status      int          `json:"status"`
+	Temperature *temperature `json:"temperature,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+// newBatchHandler builds the POST /temperature/batch handler, which looks up
+// up to maxItems CEPs, at most concurrency at a time, and answers 200 with
+// one result per CEP, in the order they were sent. Every CEP costs its client
+// a token of limiter, so a batch can't get around the rate limit.
+func newBatchHandler(serviceB temperatureService, limiter *ratelimit.Limiter, maxItems, concurrency int) http.HandlerFunc {
+	concurrency = max(concurrency, 1)
+	return func(w http.ResponseWriter, r *http.Request) {
+		// O span do servidor já foi iniciado pelo middleware de telemetria
+		ctx := r.Context()
+		span := trace.SpanFromContext(ctx)
+
+		var req batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(ctx, "Error decoding request body", "error", err)
+			telemetry.RecordError(span, telemetry.WithCategory(err, "invalid_request"))
+			writeError(w, r, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if len(req.Ceps) == 0 || len(req.Ceps) > maxItems {
+			err := telemetry.WithCategory(fmt.Errorf("batch must have between 1 and %d CEPs, got %d", maxItems, len(req.Ceps)), "invalid_request")
+			slog.WarnContext(ctx, "Invalid batch size", "size", len(req.Ceps))
+			telemetry.RecordError(span, err, attribute.Int("batch.size", len(req.Ceps)))
+			writeError(w, r, http.StatusBadRequest, fmt.Sprintf("ceps must have between 1 and %d items", maxItems))
+			return
+		}
+		span.SetAttributes(attribute.Int("batch.size", len(req.Ceps)))
+		if limiter != nil && !limiter.Allow(w, r, clientKey(r), len(req.Ceps)) {
+			return
+		}
+
+		// Cada CEP ocupa uma vaga do semáforo enquanto consulta o Serviço B
+		results := make([]batchResult, len(req.Ceps))
+		slots := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+		for i, cep := range req.Ceps {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+				results[i] = lookupBatchItem(serviceB, i, cep, ctx)
+			}()
+		}
+		wg.Wait()
+
+		failed := 0
+		for _, res := range results {
+			if res.Status != http.StatusOK {
+				failed++
+			}
+		}
+		span.SetAttributes(attribute.Int("batch.failed", failed))
+		slog.InfoContext(ctx, "Batch answered", "size", len(results), "failed", failed)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(batchResponse{Results: results})
+	}
+}
+
+// lookupBatchItem looks up the CEP at index i of a batch in its own span.
+func lookupBatchItem(serviceB temperatureService, i int, cep string, ctx context.Context) batchResult {
+	ctx, span := otel.Tracer("service-a").Start(ctx, "TemperatureBatchItemSpan", trace.WithAttributes(
+		attribute.Int("batch.index", i),
+		attribute.String("address.cep", cep),
+	))
+	defer span.End()
+
+	res := batchResult{Cep: cep, Status: http.StatusOK}
+	var err error
+	if _, err = checkCep(cep); err == nil {
+		res.Temperature, err = serviceB.getTemperature(cep, ctx)
+	}
+	if err != nil {
+		res.Status, res.Error = temperatureStatus(ctx, err)
+		slog.WarnContext(ctx, "Batch item failed", "cep", cep, "status", res.Status, "error", err)
+		telemetry.RecordError(span, err)
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", res.Status))
+	return res
+}
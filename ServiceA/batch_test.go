@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/ServiceA/configs"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestBatchHandlerAnswersEachCep(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	var inFlight, peak atomic.Int32
+	serviceB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		if n > peak.Load() {
+			peak.Store(n)
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		switch strings.TrimPrefix(r.URL.Path, "/temperature/") {
+		case "01001000":
+			w.Write([]byte(`{"city":"São Paulo","temp_c":28.5}`))
+		case "99999999":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer serviceB.Close()
+
+	u, _ := url.Parse(serviceB.URL)
+	port, _ := strconv.Atoi(u.Port())
+	client, err := newServiceBClient(&configs.Config{ServiceBHost: "http://" + u.Hostname(), ServiceBPort: port}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	r := chi.NewRouter()
+	r.Use(telemetry.NewServerMiddleware("service-a", routePattern))
+	r.Post("/temperature", newHandler(client))
+	r.Post("/temperature/batch", newBatchHandler(client, nil, 10, 2))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	body := `{"ceps":["01001000","123","99999999","22222222","01001000","01001000"]}`
+	resp, err := http.Post(srv.URL+"/temperature/batch", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, but got %d", resp.StatusCode)
+	}
+
+	var got batchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("Expected a JSON body, but got %v", err)
+	}
+	want := []int{http.StatusOK, http.StatusUnprocessableEntity, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK, http.StatusOK}
+	if len(got.Results) != len(want) {
+		t.Fatalf("Expected %d results, but got %d", len(want), len(got.Results))
+	}
+	for i, res := range got.Results {
+		if res.Status != want[i] {
+			t.Errorf("Expected result %d (%s) to have status %d, but got %d", i, res.Cep, want[i], res.Status)
+		}
+	}
+	if temp := got.Results[0].Temperature; temp == nil || temp.City != "São Paulo" || temp.TempC != 28.5 || got.Results[2].Error != "Failed to get temperature" {
+		t.Errorf("Expected the temperature and the error of each CEP, but got %+v", got.Results)
+	}
+	if peak.Load() > 2 {
+		t.Errorf("Expected at most 2 concurrent calls to Service B, but got %d", peak.Load())
+	}
+
+	// Cada CEP recebe o mesmo status nas duas rotas
+	for _, res := range got.Results[:4] {
+		single, err := http.Post(srv.URL+"/temperature", "application/json", strings.NewReader(`{"cep":"`+res.Cep+`"}`))
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		single.Body.Close()
+		if single.StatusCode != res.Status {
+			t.Errorf("Expected POST /temperature to answer %d for %s like the batch, but got %d", res.Status, res.Cep, single.StatusCode)
+		}
+	}
+
+	spans := exporter.GetSpans()
+	server := findSpan(t, spans, "POST /temperature/batch", trace.SpanID{})
+	items := 0
+	for _, s := range spans {
+		if s.Name == "TemperatureBatchItemSpan" && s.Parent.SpanID() == server.SpanContext.SpanID() {
+			items++
+		}
+	}
+	if items != len(want) {
+		t.Errorf("Expected %d item spans under the server span, but got %d", len(want), items)
+	}
+	if failed := attributeOf(server, "batch.failed").AsInt64(); failed != 3 {
+		t.Errorf("Expected batch.failed 3, but got %d", failed)
+	}
+	if server.Status.Code == codes.Error {
+		t.Errorf("Expected the server span not to be an error when only some items failed")
+	}
+}
+
+func TestBatchHandlerRejectsOversizedBatch(t *testing.T) {
+	srv := httptest.NewServer(newBatchHandler(&serviceBClient{}, nil, 2, 1))
+	defer srv.Close()
+
+	for _, body := range []string{`{"ceps":[]}`, `{"ceps":["01001000","01001000","01001000"]}`} {
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, but got %d", body, resp.StatusCode)
+		}
+	}
+}
+
+func TestBatchHandlerChargesATokenPerCep(t *testing.T) {
+	serviceB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"city":"São Paulo","temp_c":28.5}`))
+	}))
+	defer serviceB.Close()
+
+	u, _ := url.Parse(serviceB.URL)
+	port, _ := strconv.Atoi(u.Port())
+	client, err := newServiceBClient(&configs.Config{ServiceBHost: "http://" + u.Hostname(), ServiceBPort: port}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	limiter := newRateLimiter(&configs.Config{RateLimitClientRate: 0.001, RateLimitClientBurst: 5})
+	srv := httptest.NewServer(newBatchHandler(client, limiter, 10, 2))
+	defer srv.Close()
+
+	post := func(body string) *http.Response {
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := post(`{"ceps":["01001000","01001000","01001000","01001000"]}`)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("RateLimit-Remaining") != "1" {
+		t.Fatalf("Expected a batch of 4 to leave 1 token, but got status %d and RateLimit-Remaining %s", resp.StatusCode, resp.Header.Get("RateLimit-Remaining"))
+	}
+	if resp := post(`{"ceps":["01001000","01001000"]}`); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 once the batches drained the bucket, but got %d", resp.StatusCode)
+	}
+}
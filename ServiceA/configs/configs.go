@@ -2,6 +2,7 @@ package configs
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -9,6 +10,90 @@ import (
 type Config struct {
 	ServiceBHost string `mapstructure:"SERVICE_B_HOST"`
 	ServiceBPort int    `mapstructure:"SERVICE_B_PORT"`
+
+	// Transporte usado para chamar o Serviço B: "http" (padrão) ou "grpc",
+	// que usa o TemperatureService em SERVICE_B_GRPC_ADDRESS.
+	ServiceBTransport   string `mapstructure:"SERVICE_B_TRANSPORT"`
+	ServiceBGRPCAddress string `mapstructure:"SERVICE_B_GRPC_ADDRESS"`
+
+	// Cliente HTTP do Serviço B, criado uma única vez. HTTP_CLIENT_INSECURE
+	// com "service-b" desliga a verificação do certificado (apenas para
+	// testes locais).
+	HTTPClientTimeout             time.Duration `mapstructure:"HTTP_CLIENT_TIMEOUT"`
+	HTTPClientDialTimeout         time.Duration `mapstructure:"HTTP_CLIENT_DIAL_TIMEOUT"`
+	HTTPClientTLSHandshakeTimeout time.Duration `mapstructure:"HTTP_CLIENT_TLS_HANDSHAKE_TIMEOUT"`
+	HTTPClientIdleConnTimeout     time.Duration `mapstructure:"HTTP_CLIENT_IDLE_CONN_TIMEOUT"`
+	HTTPClientMaxIdleConnsPerHost int           `mapstructure:"HTTP_CLIENT_MAX_IDLE_CONNS_PER_HOST"`
+	HTTPClientMaxConnsPerHost     int           `mapstructure:"HTTP_CLIENT_MAX_CONNS_PER_HOST"`
+	HTTPClientCAFile              string        `mapstructure:"HTTP_CLIENT_CA_FILE"`
+	HTTPClientInsecure            []string      `mapstructure:"HTTP_CLIENT_INSECURE"`
+
+	// Novas tentativas para erros de rede e os status listados, com espera
+	// exponencial a partir de RETRY_BASE_DELAY e até RETRY_MAX_DELAY.
+	// RETRY_MAX_ATTEMPTS inclui a primeira tentativa; 1 desliga as novas
+	// tentativas.
+	RetryMaxAttempts int           `mapstructure:"RETRY_MAX_ATTEMPTS"`
+	RetryBaseDelay   time.Duration `mapstructure:"RETRY_BASE_DELAY"`
+	RetryMaxDelay    time.Duration `mapstructure:"RETRY_MAX_DELAY"`
+	RetryJitter      float64       `mapstructure:"RETRY_JITTER"`
+	RetryStatusCodes []int         `mapstructure:"RETRY_STATUS_CODES"`
+
+	// Circuit breaker de cada upstream: abre após BREAKER_FAILURE_THRESHOLD
+	// falhas seguidas, fica aberto por BREAKER_OPEN_TIMEOUT e então deixa
+	// passar BREAKER_HALF_OPEN_REQUESTS chamadas de teste.
+	BreakerFailureThreshold int           `mapstructure:"BREAKER_FAILURE_THRESHOLD"`
+	BreakerOpenTimeout      time.Duration `mapstructure:"BREAKER_OPEN_TIMEOUT"`
+	BreakerHalfOpenRequests int           `mapstructure:"BREAKER_HALF_OPEN_REQUESTS"`
+
+	// Chaves de API aceitas em POST /temperature, no formato nome=chave
+	// separado por vírgula e/ou uma por linha em API_KEYS_FILE. Sem chaves, a
+	// autenticação fica desligada.
+	APIKeys     []string `mapstructure:"API_KEYS"`
+	APIKeysFile string   `mapstructure:"API_KEYS_FILE"`
+
+	// Segredo HMAC do token que o Serviço A envia ao Serviço B e a validade
+	// de cada token.
+	ServiceTokenSecret string        `mapstructure:"SERVICE_TOKEN_SECRET"`
+	ServiceTokenTTL    time.Duration `mapstructure:"SERVICE_TOKEN_TTL"`
+
+	// Limite de requisições em POST /temperature por cliente (chave de API
+	// ou IP) e o teto somado de todos os clientes, em requisições por
+	// segundo, com a rajada aceita acima da taxa. Taxa 0 desliga o limite.
+	RateLimitClientRate  float64 `mapstructure:"RATE_LIMIT_CLIENT_RATE"`
+	RateLimitClientBurst int     `mapstructure:"RATE_LIMIT_CLIENT_BURST"`
+	RateLimitGlobalRate  float64 `mapstructure:"RATE_LIMIT_GLOBAL_RATE"`
+	RateLimitGlobalBurst int     `mapstructure:"RATE_LIMIT_GLOBAL_BURST"`
+
+	// POST /temperature/batch: máximo de CEPs por requisição e quantos são
+	// consultados ao mesmo tempo no Serviço B.
+	BatchMaxItems    int `mapstructure:"BATCH_MAX_ITEMS"`
+	BatchConcurrency int `mapstructure:"BATCH_CONCURRENCY"`
+
+	// Logs: nível (debug, info, warn, error), formato do console (text ou
+	// json) e se os registros também são escritos no console.
+	LogLevel   string `mapstructure:"LOG_LEVEL"`
+	LogFormat  string `mapstructure:"LOG_FORMAT"`
+	LogConsole bool   `mapstructure:"LOG_CONSOLE"`
+
+	// Chaves do baggage copiadas para spans, logs e métricas, separadas por
+	// vírgula.
+	BaggageKeys []string `mapstructure:"BAGGAGE_KEYS"`
+
+	// Prazo de cada requisição quando o cliente não envia X-Request-Timeout,
+	// e o maior prazo aceito do cliente.
+	RequestTimeout    time.Duration `mapstructure:"REQUEST_TIMEOUT"`
+	RequestTimeoutMax time.Duration `mapstructure:"REQUEST_TIMEOUT_MAX"`
+
+	// Injeção de falhas (latência, erros e timeouts) para testes. Desligada
+	// por padrão; as regras iniciais são um array JSON e podem ser trocadas
+	// em tempo de execução via /admin/faults.
+	FaultsEnabled bool   `mapstructure:"FAULTS_ENABLED"`
+	FaultsRules   string `mapstructure:"FAULTS_RULES"`
+
+	// Chaves das rotas de administração (/admin), no formato nome=chave
+	// separado por vírgula e enviadas no cabeçalho X-API-Key. Sem chaves, as
+	// rotas de administração não existem.
+	AdminAPIKeys []string `mapstructure:"ADMIN_API_KEYS"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -17,6 +102,47 @@ func LoadConfig() (*Config, error) {
 	viper.SetConfigName(".env")
 	viper.SetConfigType("env")
 
+	// Valores padrão também tornam as chaves visíveis para o AutomaticEnv.
+	viper.SetDefault("SERVICE_B_HOST", "http://localhost")
+	viper.SetDefault("SERVICE_B_PORT", 8081)
+	viper.SetDefault("SERVICE_B_TRANSPORT", "http")
+	viper.SetDefault("SERVICE_B_GRPC_ADDRESS", "localhost:50051")
+	viper.SetDefault("HTTP_CLIENT_TIMEOUT", "10s")
+	viper.SetDefault("HTTP_CLIENT_DIAL_TIMEOUT", "2s")
+	viper.SetDefault("HTTP_CLIENT_TLS_HANDSHAKE_TIMEOUT", "2s")
+	viper.SetDefault("HTTP_CLIENT_IDLE_CONN_TIMEOUT", "90s")
+	viper.SetDefault("HTTP_CLIENT_MAX_IDLE_CONNS_PER_HOST", 64)
+	viper.SetDefault("HTTP_CLIENT_MAX_CONNS_PER_HOST", 256)
+	viper.SetDefault("HTTP_CLIENT_CA_FILE", "")
+	viper.SetDefault("HTTP_CLIENT_INSECURE", "")
+	viper.SetDefault("RETRY_MAX_ATTEMPTS", 3)
+	viper.SetDefault("RETRY_BASE_DELAY", "100ms")
+	viper.SetDefault("RETRY_MAX_DELAY", "2s")
+	viper.SetDefault("RETRY_JITTER", 0.5)
+	viper.SetDefault("RETRY_STATUS_CODES", "429,500,502,503,504")
+	viper.SetDefault("BREAKER_FAILURE_THRESHOLD", 5)
+	viper.SetDefault("BREAKER_OPEN_TIMEOUT", "30s")
+	viper.SetDefault("BREAKER_HALF_OPEN_REQUESTS", 1)
+	viper.SetDefault("API_KEYS", "")
+	viper.SetDefault("API_KEYS_FILE", "")
+	viper.SetDefault("SERVICE_TOKEN_SECRET", "")
+	viper.SetDefault("SERVICE_TOKEN_TTL", "1m")
+	viper.SetDefault("RATE_LIMIT_CLIENT_RATE", 5)
+	viper.SetDefault("RATE_LIMIT_CLIENT_BURST", 10)
+	viper.SetDefault("RATE_LIMIT_GLOBAL_RATE", 50)
+	viper.SetDefault("RATE_LIMIT_GLOBAL_BURST", 100)
+	viper.SetDefault("BATCH_MAX_ITEMS", 50)
+	viper.SetDefault("BATCH_CONCURRENCY", 8)
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("LOG_FORMAT", "text")
+	viper.SetDefault("LOG_CONSOLE", true)
+	viper.SetDefault("BAGGAGE_KEYS", "tenant.id,client.app,request.origin")
+	viper.SetDefault("REQUEST_TIMEOUT", "5s")
+	viper.SetDefault("REQUEST_TIMEOUT_MAX", "30s")
+	viper.SetDefault("FAULTS_ENABLED", false)
+	viper.SetDefault("FAULTS_RULES", "")
+	viper.SetDefault("ADMIN_API_KEYS", "")
+
 	// 2. Habilita a leitura automática de variáveis de ambiente do SO.
 	viper.AutomaticEnv()
 
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/ServiceA/configs"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/auth"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/breaker"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/temperaturepb"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServiceBClient calls the TemperatureService of Service B. The otelgrpc
+// stats handler creates a client span for every call and propagates the trace
+// context, and gRPC itself sends the remaining deadline.
+type grpcServiceBClient struct {
+	target  string
+	client  temperaturepb.TemperatureServiceClient
+	breaker *breaker.Breaker
+}
+
+// newGRPCServiceBClient builds the gRPC client of Service B. Calls are signed
+// with a fresh service token when SERVICE_TOKEN_SECRET is set, and retried
+// by gRPC while Service B is unavailable.
+func newGRPCServiceBClient(config *configs.Config) (*grpcServiceBClient, error) {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithDefaultServiceConfig(grpcServiceConfig(config)),
+	}
+	if config.ServiceTokenSecret != "" {
+		signer := auth.NewSigner("service-a", "service-b", []byte(config.ServiceTokenSecret), config.ServiceTokenTTL)
+		opts = append(opts, grpc.WithPerRPCCredentials(signer))
+	} else {
+		slog.Warn("SERVICE_TOKEN_SECRET is not set, calls to Service B are not signed")
+	}
+
+	conn, err := grpc.NewClient(config.ServiceBGRPCAddress, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("service-b: %w", err)
+	}
+	return &grpcServiceBClient{
+		target:  config.ServiceBGRPCAddress,
+		client:  temperaturepb.NewTemperatureServiceClient(conn),
+		breaker: newServiceBBreaker(config),
+	}, nil
+}
+
+// grpcServiceConfig translates the RETRY_* settings into the retry policy of
+// the gRPC service config. Only UNAVAILABLE is retried: the other codes of
+// Service B are answers, not transient failures.
+func grpcServiceConfig(config *configs.Config) string {
+	if config.RetryMaxAttempts <= 1 || config.RetryBaseDelay <= 0 || config.RetryMaxDelay <= 0 {
+		return `{}`
+	}
+	seconds := func(d time.Duration) string {
+		return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+	}
+	serviceConfig, _ := json.Marshal(map[string]any{
+		"methodConfig": []map[string]any{{
+			"name": []map[string]string{{"service": "temperature.v1.TemperatureService"}},
+			"retryPolicy": map[string]any{
+				"maxAttempts":          config.RetryMaxAttempts,
+				"initialBackoff":       seconds(config.RetryBaseDelay),
+				"maxBackoff":           seconds(config.RetryMaxDelay),
+				"backoffMultiplier":    2,
+				"retryableStatusCodes": []string{"UNAVAILABLE"},
+			},
+		}},
+	})
+	return string(serviceConfig)
+}
+
+func (s *grpcServiceBClient) getTemperature(cep string, ctx context.Context) (_ *temperature, err error) {
+	// Inicia um span filho; o span do cliente gRPC fica abaixo dele
+	ctx, span := otel.Tracer("service-a").Start(ctx, "GetTemperatureSpan")
+	defer func() {
+		if err != nil {
+			telemetry.RecordError(span, err, attribute.String("address.cep", cep))
+		}
+		span.End()
+	}()
+
+	// Com o breaker aberto a chamada falha na hora, sem esperar o Serviço B
+	done, err := s.breaker.Allow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.GetTemperature(ctx, &temperaturepb.GetTemperatureRequest{Cep: cep})
+	if err != nil {
+		st := status.Convert(err)
+		if st.Code() == codes.Canceled {
+			done(false)
+			return nil, fmt.Errorf("error during request to service B: %w", err)
+		}
+		statusErr := &telemetry.StatusError{StatusCode: httpStatus(st.Code()), Host: s.target}
+		done(statusErr.StatusCode >= http.StatusInternalServerError)
+		return nil, fmt.Errorf("service B answered: %w with status %s: %s", statusErr, st.Code(), st.Message())
+	}
+	done(false)
+
+	t := &temperature{
+		City:  resp.GetCity(),
+		TempC: resp.GetTempC(),
+		TempK: resp.GetTempK(),
+		TempF: resp.GetTempF(),
+		Age:   resp.GetAge(),
+	}
+	if resp.GetObservedAt() != nil {
+		t.ObservedAt = resp.GetObservedAt().AsTime()
+	}
+	return t, nil
+}
+
+// httpStatus maps the code Service B answered to the status of its HTTP route,
+// so handlers treat both transports alike.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument:
+		return http.StatusUnprocessableEntity
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/ServiceA/configs"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/auth"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/temperaturepb"
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// serviceBStandIn answers 01001000 with 28.5°C and every other CEP with
+// NotFound, keeping the metadata of the last call.
+type serviceBStandIn struct {
+	temperaturepb.UnimplementedTemperatureServiceServer
+	received metadata.MD
+}
+
+func (s *serviceBStandIn) GetTemperature(ctx context.Context, req *temperaturepb.GetTemperatureRequest) (*temperaturepb.GetTemperatureResponse, error) {
+	s.received, _ = metadata.FromIncomingContext(ctx)
+	if req.GetCep() != "01001000" {
+		return nil, status.Error(codes.NotFound, "can not find zipcode")
+	}
+	return &temperaturepb.GetTemperatureResponse{
+		City:       "São Paulo",
+		TempC:      28.5,
+		TempF:      83.3,
+		TempK:      301.5,
+		ObservedAt: timestamppb.New(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)),
+	}, nil
+}
+
+// newTestGRPCServer serves POST /temperature in front of a gRPC Service B
+// stand-in, recording every span in the returned exporter.
+func newTestGRPCServer(t *testing.T, config *configs.Config) (*httptest.Server, *serviceBStandIn, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	standIn := &serviceBStandIn{}
+	grpcServer := grpc.NewServer()
+	temperaturepb.RegisterTemperatureServiceServer(grpcServer, standIn)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	config.ServiceBTransport = "grpc"
+	config.ServiceBGRPCAddress = lis.Addr().String()
+	client, err := newTemperatureService(config, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(telemetry.NewServerMiddleware("service-a", routePattern))
+	r.Post("/temperature", newHandler(client))
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv, standIn, exporter
+}
+
+func TestHandlerCallsServiceBOverGRPC(t *testing.T) {
+	srv, standIn, exporter := newTestGRPCServer(t, &configs.Config{ServiceTokenSecret: "secret"})
+
+	resp, err := http.Post(srv.URL+"/temperature", "application/json", bytes.NewBufferString(`{"cep":"01001000"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, but got %d", resp.StatusCode)
+	}
+	var got temperature
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("Expected a JSON temperature, but got %v", err)
+	}
+	if got.City != "São Paulo" || got.TempC != 28.5 || !got.ObservedAt.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the temperature of São Paulo, but got %+v", got)
+	}
+
+	authorization := standIn.received.Get("authorization")
+	if len(authorization) != 1 {
+		t.Fatalf("Expected a bearer token in the metadata, but got %v", standIn.received)
+	}
+	token, _ := strings.CutPrefix(authorization[0], "Bearer ")
+	if _, err := auth.NewVerifier("service-b", []string{"service-a"}, []byte("secret")).Verify(token); err != nil {
+		t.Errorf("Expected a valid service token, but got %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	server := findSpan(t, spans, "POST /temperature", trace.SpanID{})
+	call := findSpan(t, spans, "GetTemperatureSpan", server.SpanContext.SpanID())
+	rpc := findSpan(t, spans, "temperature.v1.TemperatureService/GetTemperature", call.SpanContext.SpanID())
+	if rpc.SpanKind != trace.SpanKindClient {
+		t.Errorf("Expected a client span for the RPC, but got %s", rpc.SpanKind)
+	}
+	traceparent := standIn.received.Get("traceparent")
+	if len(traceparent) != 1 || !strings.Contains(traceparent[0], rpc.SpanContext.SpanID().String()) {
+		t.Errorf("Expected the RPC span in traceparent, but got %v", traceparent)
+	}
+}
+
+func TestGRPCClientMapsStatusCodes(t *testing.T) {
+	srv, _, exporter := newTestGRPCServer(t, &configs.Config{})
+
+	resp, err := http.Post(srv.URL+"/temperature", "application/json", bytes.NewBufferString(`{"cep":"99999999"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, but got %d", resp.StatusCode)
+	}
+
+	server := findSpan(t, exporter.GetSpans(), "POST /temperature", trace.SpanID{})
+	call := findSpan(t, exporter.GetSpans(), "GetTemperatureSpan", server.SpanContext.SpanID())
+	if got := attributeOf(call, "upstream.status_code").AsInt64(); got != http.StatusNotFound {
+		t.Errorf("Expected NotFound to be recorded as status 404, but got %d", got)
+	}
+
+	for code, want := range map[codes.Code]int{
+		codes.InvalidArgument:  http.StatusUnprocessableEntity,
+		codes.DeadlineExceeded: http.StatusGatewayTimeout,
+		codes.Unavailable:      http.StatusServiceUnavailable,
+		codes.Internal:         http.StatusInternalServerError,
+	} {
+		if got := httpStatus(code); got != want {
+			t.Errorf("Expected %s to map to %d, but got %d", code, want, got)
+		}
+	}
+}
@@ -3,226 +3,440 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/EnnioSimoes/2-Observabilidade/ServiceA/configs"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/auth"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/breaker"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/deadline"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/faults"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/httpclient"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/retry"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
 	"github.com/go-chi/chi"
-	"github.com/go-chi/chi/middleware"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type cepRequest struct {
-	Cep string `json:"cep"`
+var (
+	errCepRequired = telemetry.WithCategory(errors.New("CEP is required"), "invalid_request")
+	errInvalidCep  = telemetry.WithCategory(errors.New("invalid zipcode"), "invalid_cep")
+)
+
+// temperature is the answer of Service B, whichever transport carried it.
+type temperature struct {
+	City       string    `json:"city"`
+	TempC      float64   `json:"temp_c"`
+	TempK      float64   `json:"temp_k"`
+	TempF      float64   `json:"temp_f"`
+	ObservedAt time.Time `json:"observed_at,omitzero"`
+	Age        int64     `json:"age"`
 }
 
-var serviceName = semconv.ServiceNameKey.String("service-a")
+// temperatureService looks up temperatures in Service B. Failures carry a
+// *telemetry.StatusError with the HTTP status Service B answered, or its
+// equivalent for gRPC, so handlers don't depend on the transport.
+type temperatureService interface {
+	getTemperature(cep string, ctx context.Context) (*temperature, error)
+}
 
-// Initialize a gRPC connection to be used by both the tracer and meter
-// providers.
-func initConn() (*grpc.ClientConn, error) {
-	// It connects the OpenTelemetry Collector through local gRPC connection.
-	// You may replace `localhost:4317` with your endpoint.
-	conn, err := grpc.NewClient("otel-collector:4317",
-		// Note the use of insecure transport here. TLS is recommended in production.
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create gRPC connection to collector: %w", err)
+// newTemperatureService builds the Service B client for the transport set in
+// SERVICE_B_TRANSPORT.
+func newTemperatureService(config *configs.Config, injector *faults.Injector) (temperatureService, error) {
+	switch config.ServiceBTransport {
+	case "", "http":
+		return newServiceBClient(config, injector)
+	case "grpc":
+		if injector != nil {
+			slog.Warn("Injected faults do not apply to gRPC calls to Service B")
+		}
+		return newGRPCServiceBClient(config)
 	}
+	return nil, fmt.Errorf("unknown SERVICE_B_TRANSPORT %q, expected http or grpc", config.ServiceBTransport)
+}
 
-	return conn, err
+// newServiceBBreaker builds the breaker shared by both transports to Service B.
+func newServiceBBreaker(config *configs.Config) *breaker.Breaker {
+	return breaker.New("service-a", "service-b", breaker.Settings{
+		FailureThreshold: config.BreakerFailureThreshold,
+		OpenTimeout:      config.BreakerOpenTimeout,
+		HalfOpenRequests: config.BreakerHalfOpenRequests,
+	})
 }
 
-// Initializes an OTLP exporter, and configures the corresponding trace provider.
-func initTracerProvider(ctx context.Context, res *resource.Resource, conn *grpc.ClientConn) (func(context.Context) error, error) {
-	// Set up a trace exporter
-	traceExporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
-	}
+// serviceBClient calls Service B through a client built once at startup,
+// which creates a client span for every call and propagates the trace context
+// and the remaining deadline.
+type serviceBClient struct {
+	baseURL string
+	client  *http.Client
+	breaker *breaker.Breaker
+}
 
-	// Register the trace exporter with a TracerProvider, using a batch
-	// span processor to aggregate spans before export.
-	bsp := sdktrace.NewBatchSpanProcessor(traceExporter)
-	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-		sdktrace.WithResource(res),
-		sdktrace.WithSpanProcessor(bsp),
-	)
-	otel.SetTracerProvider(tracerProvider)
+// newServiceBClient builds the Service B client. Injected faults sit below the
+// instrumented transport so they show up on its client span, and every
+// attempt is signed with a fresh service token when SERVICE_TOKEN_SECRET is
+// set.
+func newServiceBClient(config *configs.Config, injector *faults.Injector) (*serviceBClient, error) {
+	opts := httpclient.Options{
+		Timeout:             config.HTTPClientTimeout,
+		DialTimeout:         config.HTTPClientDialTimeout,
+		TLSHandshakeTimeout: config.HTTPClientTLSHandshakeTimeout,
+		IdleConnTimeout:     config.HTTPClientIdleConnTimeout,
+		MaxIdleConnsPerHost: config.HTTPClientMaxIdleConnsPerHost,
+		MaxConnsPerHost:     config.HTTPClientMaxConnsPerHost,
+		CAFile:              config.HTTPClientCAFile,
+		Insecure:            slices.Contains(config.HTTPClientInsecure, "service-b"),
+	}
+	if opts.Insecure {
+		slog.Warn("TLS certificate verification is disabled", "upstream", "service-b")
+	}
 
-	// Set global propagator to tracecontext (the default is no-op).
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+	var wrappers []httpclient.Wrapper
+	if injector != nil {
+		wrappers = append(wrappers, injector.Transport)
+	}
+	wrappers = append(wrappers, deadline.NewTransport)
+	if config.ServiceTokenSecret != "" {
+		signer := auth.NewSigner("service-a", "service-b", []byte(config.ServiceTokenSecret), config.ServiceTokenTTL)
+		wrappers = append(wrappers, signer.Transport)
+	} else {
+		slog.Warn("SERVICE_TOKEN_SECRET is not set, calls to Service B are not signed")
+	}
+	wrappers = append(wrappers, func(next http.RoundTripper) http.RoundTripper {
+		return telemetry.NewTransport("service-a", next)
+	}, newRetryWrapper(config))
 
-	// Shutdown will flush any remaining spans and shut down the exporter.
-	return tracerProvider.Shutdown, nil
+	client, err := httpclient.New(opts, wrappers...)
+	if err != nil {
+		return nil, fmt.Errorf("service-b: %w", err)
+	}
+	return &serviceBClient{
+		baseURL: fmt.Sprintf("%s:%d", config.ServiceBHost, config.ServiceBPort),
+		client:  client,
+		breaker: newServiceBBreaker(config),
+	}, nil
 }
 
-func handler(w http.ResponseWriter, r *http.Request) {
-	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
-	/**
-	 * Instrumenta o handler com OpenTelemetry.
-	 */
-	// Pega o tracer global que configuramos
-	tracer := otel.Tracer("service-a")
+type cepRequest struct {
+	Cep string `json:"cep"`
+}
 
-	// Inicia um novo span. O contexto da requisição é usado como pai.
-	ctx, span := tracer.Start(ctx, "StartHandlerSpan")
-	defer span.End() // É crucial finalizar o span
+// newRetryWrapper resends failed calls around the instrumented transport, so
+// every attempt gets its own client span.
+func newRetryWrapper(config *configs.Config) httpclient.Wrapper {
+	policy := retry.Policy{
+		MaxAttempts: config.RetryMaxAttempts,
+		BaseDelay:   config.RetryBaseDelay,
+		MaxDelay:    config.RetryMaxDelay,
+		Jitter:      config.RetryJitter,
+		StatusCodes: config.RetryStatusCodes,
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return retry.NewTransport(policy, next)
+	}
+}
 
-	time.Sleep(1 * time.Second) // Simula algum processamento
-	/**
-	 * Fim da instrumentação do handler.
-	 */
+// newHandler builds the POST /temperature handler on top of the Service B
+// client.
+func newHandler(serviceB temperatureService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// O span do servidor já foi iniciado pelo middleware de telemetria
+		ctx := r.Context()
+		span := trace.SpanFromContext(ctx)
+
+		slog.InfoContext(ctx, "Received request", "path", r.URL.Path)
+		var req cepRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(ctx, "Error decoding request body", "error", err)
+			telemetry.RecordError(span, telemetry.WithCategory(err, "invalid_request"))
+			writeError(w, r, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		cep := req.Cep
 
-	// Adiciona atributos ao span para dar mais detalhes
-	span.SetAttributes(
-		attribute.String("http.method", r.Method),
-		attribute.String("http.url", r.URL.String()),
-	)
+		if cep == "" {
+			slog.WarnContext(ctx, "No CEP provided in the request")
+			telemetry.RecordError(span, errCepRequired)
+			writeError(w, r, http.StatusBadRequest, "CEP is required")
+			return
+		}
+		slog.InfoContext(ctx, "Extracted CEP", "cep", cep)
+
+		_, err := checkCep(cep)
+		if err != nil {
+			slog.WarnContext(ctx, "Invalid CEP", "cep", cep, "error", err)
+			telemetry.RecordError(span, err, attribute.String("address.cep", cep))
+			writeError(w, r, http.StatusUnprocessableEntity, "Invalid zipcode")
+			return
+		}
 
-	log.Println("Received request for:", r.URL.Path)
-	var req cepRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Println("Error decoding request body:", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-	cep := req.Cep
+		temperature, err := serviceB.getTemperature(cep, ctx)
+		if err != nil {
+			status, message := temperatureStatus(ctx, err)
+			switch status {
+			case http.StatusGatewayTimeout:
+				// O prazo acabou aqui ou no Serviço B
+				writeDeadlineExceeded(w, r, err, attribute.String("address.cep", cep))
+				return
+			case http.StatusServiceUnavailable:
+				var openErr *breaker.OpenError
+				errors.As(err, &openErr)
+				slog.WarnContext(ctx, "Service B circuit breaker is open", "cep", cep)
+				w.Header().Set("Retry-After", strconv.Itoa(openErr.RetryAfterSeconds()))
+			default:
+				slog.ErrorContext(ctx, "Error getting temperature", "cep", cep, "error", err)
+			}
+			telemetry.RecordError(span, err, attribute.String("address.cep", cep))
+			writeError(w, r, status, message)
+			return
+		}
 
-	if cep == "" {
-		log.Println("No CEP provided in the request")
-		http.Error(w, "CEP is required", http.StatusBadRequest)
-		return
+		slog.InfoContext(ctx, "Temperature data received", "city", temperature.City, "temp_c", temperature.TempC)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(temperature); err != nil {
+			slog.ErrorContext(ctx, "Error writing response", "error", err)
+			return
+		}
+		slog.InfoContext(ctx, "Response sent successfully")
 	}
-	log.Println("Extracted CEP:", cep)
+}
 
-	_, err := checkCep(cep)
-	if err != nil {
-		log.Printf("Invalid CEP: %v\n", err)
-		http.Error(w, "Invalid zipcode", http.StatusUnprocessableEntity)
-		return
+// temperatureStatus maps a failed temperature lookup to the status and
+// message answered for that CEP, by POST /temperature and by each item of
+// POST /temperature/batch alike. Answers of Service B other than 504 are
+// failures of the lookup and become a 500.
+func temperatureStatus(ctx context.Context, err error) (int, string) {
+	var statusErr *telemetry.StatusError
+	errors.As(err, &statusErr)
+	switch {
+	case errors.Is(err, errInvalidCep):
+		return http.StatusUnprocessableEntity, "Invalid zipcode"
+	case deadline.Exceeded(ctx) || statusErr != nil && statusErr.StatusCode == http.StatusGatewayTimeout:
+		return http.StatusGatewayTimeout, "Request deadline exceeded"
+	case errors.As(err, new(*breaker.OpenError)):
+		return http.StatusServiceUnavailable, "Service unavailable"
 	}
+	return http.StatusInternalServerError, "Failed to get temperature"
+}
 
-	temperature, err := getTemperature(cep, ctx)
-	if err != nil {
-		log.Printf("Error getting temperature: %v\n", err)
-		http.Error(w, "Failed to get temperature", http.StatusInternalServerError)
-		return
-	}
+// errorResponse is the JSON body of every error answer. TraceID lets support
+// find the request in Zipkin.
+type errorResponse struct {
+	Error   string `json:"error"`
+	TraceID string `json:"trace_id,omitempty"`
+}
 
-	log.Println("Temperature data received:", temperature)
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write([]byte(temperature))
-	if err != nil {
-		log.Printf("Error writing response: %v\n", err)
-		return
-	}
-	log.Println("Response sent successfully")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: message, TraceID: telemetry.TraceID(r.Context())})
+}
+
+// writeDeadlineExceeded answers 504 when the request ran out of its deadline
+// budget while waiting for err.
+func writeDeadlineExceeded(w http.ResponseWriter, r *http.Request, err error, attrs ...attribute.KeyValue) {
+	ctx := r.Context()
+	slog.WarnContext(ctx, "Request deadline exceeded", "error", err)
+	telemetry.RecordError(trace.SpanFromContext(ctx), fmt.Errorf("%w: %w", deadline.ErrExceeded, err), attrs...)
+	writeError(w, r, http.StatusGatewayTimeout, "Request deadline exceeded")
 }
 
-func getTemperature(cep string, ctx context.Context) (string, error) {
+func (s *serviceBClient) getTemperature(cep string, ctx context.Context) (_ *temperature, err error) {
 	// Intrumenta o span para a chamada interna
 	// Pega o tracer novamente (ou poderia ser passado como argumento)
 	tracer := otel.Tracer("service-a")
 
 	// Inicia um span filho, pois estamos usando o contexto do `helloHandlerSpan`
-	_, span := tracer.Start(ctx, "GetTemperatureSpan")
-	defer span.End()
-
-	// ctx := context.Background()
-	// ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
-	_, cancel := context.WithTimeout(ctx, 2*time.Second)
-	defer cancel()
-
-	config, _ := configs.LoadConfig()
+	ctx, span := tracer.Start(ctx, "GetTemperatureSpan")
+	defer func() {
+		if err != nil {
+			telemetry.RecordError(span, err, attribute.String("address.cep", cep))
+		}
+		span.End()
+	}()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s:%d/temperature/%s", config.ServiceBHost, config.ServiceBPort, cep), nil)
+	// O prazo vem do middleware de deadline e é repassado ao Serviço B no
+	// cabeçalho X-Request-Timeout pelo transporte do cliente
+	req, err := http.NewRequestWithContext(ctx, "GET", s.baseURL+"/temperature/"+url.PathEscape(cep), nil)
 	if err != nil {
-		return "", fmt.Errorf("error creating request for service B: %w", err)
+		return nil, fmt.Errorf("error creating request for service B: %w", err)
 	}
 
-	// Withot this, the request won't carry the trace context to Service B
-	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	// Com o breaker aberto a chamada falha na hora, sem esperar o Serviço B
+	done, err := s.breaker.Allow(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	// The instrumented transport injects the trace context for Service B
+	resp, err := s.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error during request to service B: %w", err)
+		done(!errors.Is(err, context.Canceled))
+		return nil, fmt.Errorf("error during request to service B: %w", err)
 	}
+	done(resp.StatusCode >= http.StatusInternalServerError)
 
 	defer resp.Body.Close()
 
-	log.Println("Response from service B:", resp.StatusCode)
+	slog.InfoContext(ctx, "Response from service B", "status", resp.StatusCode)
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read body response: %w", err)
+		return nil, fmt.Errorf("failed to read body response: %w", err)
 	}
-	log.Println("Response body from service B:", string(body))
+	slog.DebugContext(ctx, "Response body from service B", "body", string(body))
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("service B returned status code %d with body: %s", resp.StatusCode, string(body))
+		statusErr := &telemetry.StatusError{StatusCode: resp.StatusCode, Host: req.URL.Host}
+		return nil, fmt.Errorf("service B answered: %w with body: %s", statusErr, string(body))
 	}
-	return string(body), nil
+
+	var t temperature
+	if err := json.Unmarshal(body, &t); err != nil {
+		return nil, fmt.Errorf("invalid response from service B: %w", err)
+	}
+	return &t, nil
 }
 
 func checkCep(cep string) (bool, error) {
 	if len(cep) != 8 {
-		return false, fmt.Errorf("invalid zipcode")
+		return false, errInvalidCep
 	}
 	return true, nil
 }
 
+// newAdminRouter serves the admin routes to the holders of the keys in
+// ADMIN_API_KEYS, sent in the X-API-Key header. Client API keys are not
+// accepted there.
+func newAdminRouter(keys *auth.KeyStore, injector *faults.Injector) http.Handler {
+	r := chi.NewRouter()
+	r.Use(keys.Middleware)
+	r.Handle("/faults", injector.Handler())
+	return r
+}
+
+// newFaultInjector returns nil unless FAULTS_ENABLED is set, so faults can
+// never be injected by accident. FAULTS_RULES holds the initial rules.
+func newFaultInjector(config *configs.Config) (*faults.Injector, error) {
+	if !config.FaultsEnabled {
+		return nil, nil
+	}
+	injector := faults.New()
+	if config.FaultsRules == "" {
+		return injector, nil
+	}
+	rules, err := faults.ParseRules([]byte(config.FaultsRules))
+	if err != nil {
+		return nil, err
+	}
+	if err := injector.SetRules(rules); err != nil {
+		return nil, fmt.Errorf("invalid FAULTS_RULES: %w", err)
+	}
+	return injector, nil
+}
+
+// routePattern returns the route template chi matched for r, e.g.
+// /temperature. It is only known once chi has routed the request.
+func routePattern(r *http.Request) string {
+	return chi.RouteContext(r.Context()).RoutePattern()
+}
+
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	conn, err := initConn()
+	config, err := configs.LoadConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			// The service name used to display traces in backends
-			serviceName,
-		),
+	shutdownTelemetry, err := telemetry.Setup(ctx,
+		telemetry.WithServiceName("service-a"),
+		telemetry.WithLogLevel(config.LogLevel),
+		telemetry.WithConsoleLog(config.LogConsole, config.LogFormat),
+		telemetry.WithBaggageKeys(config.BaggageKeys...),
 	)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer func() {
+		// The signal context is already canceled here, so flushing needs its own.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTelemetry(ctx); err != nil {
+			log.Printf("failed to shutdown telemetry: %s", err)
+		}
+	}()
 
-	shutdownTracerProvider, err := initTracerProvider(ctx, res, conn)
+	injector, err := newFaultInjector(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	serviceB, err := newTemperatureService(config, injector)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer func() {
-		if err := shutdownTracerProvider(ctx); err != nil {
-			log.Fatalf("failed to shutdown TracerProvider: %s", err)
-		}
-	}()
 
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Post("/temperature", handler)
+	r.Use(telemetry.NewServerMiddleware("service-a", routePattern))
+	r.Use(deadline.NewMiddleware(config.RequestTimeout, config.RequestTimeoutMax))
+	r.Use(newRequestMetadataMiddleware(config.BaggageKeys))
+	r.Use(telemetry.RequestLogger)
+	r.Use(telemetry.NewMetricsMiddleware("service-a", routePattern, config.BaggageKeys))
+	if injector != nil {
+		slog.Warn("Fault injection is enabled", "rules", len(injector.Rules()))
+		r.Use(injector.Middleware)
+	}
+	keys, err := auth.LoadKeys(config.APIKeys, config.APIKeysFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var authenticate []func(http.Handler) http.Handler
+	if keys.Len() > 0 {
+		authenticate = append(authenticate, keys.Middleware)
+	} else {
+		slog.Warn("No API key is configured, POST /temperature is not authenticated")
+	}
+	limiter := newRateLimiter(config)
+	r.With(authenticate...).With(limiter.Middleware(clientKey)).Post("/temperature", newHandler(serviceB))
+	// O lote é cobrado pelo próprio handler, um token por CEP, depois de ler o corpo
+	r.With(authenticate...).Post("/temperature/batch", newBatchHandler(serviceB, limiter, config.BatchMaxItems, config.BatchConcurrency))
 
-	log.Println("Starting server on :8080")
-	err = http.ListenAndServe(":8080", r)
+	// As rotas de administração só existem com ADMIN_API_KEYS definido
+	adminKeys, err := auth.LoadKeys(config.AdminAPIKeys, "")
 	if err != nil {
+		log.Fatal(err)
+	}
+	switch {
+	case injector == nil:
+	case adminKeys.Len() == 0:
+		slog.Warn("ADMIN_API_KEYS is not set, /admin/faults is disabled")
+	default:
+		r.Mount("/admin", newAdminRouter(adminKeys, injector))
+	}
+
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	slog.Info("Starting server", "addr", srv.Addr)
+	err = srv.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
 		log.Fatalf("An error occurred while starting the server: %v", err)
 	}
 }
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/ServiceA/configs"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/auth"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/deadline"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/faults"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestServer serves POST /temperature in front of a Service B stand-in
+// that answers every CEP with 404, recording every span in the returned
+// exporter and the headers Service B received in forwarded.
+func newTestServer(t *testing.T) (srv *httptest.Server, exporter *tracetest.InMemoryExporter, forwarded *http.Header) {
+	t.Helper()
+	exporter = tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	forwarded = &http.Header{}
+	serviceB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*forwarded = r.Header.Clone()
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"can not find zipcode"}`))
+	}))
+	t.Cleanup(serviceB.Close)
+
+	u, _ := url.Parse(serviceB.URL)
+	port, _ := strconv.Atoi(u.Port())
+	client, err := newServiceBClient(&configs.Config{ServiceBHost: "http://" + u.Hostname(), ServiceBPort: port}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(telemetry.NewServerMiddleware("service-a", routePattern))
+	r.Use(deadline.NewMiddleware(5*time.Second, 0))
+	r.Use(newRequestMetadataMiddleware([]string{"tenant.id", "client.app", "request.origin"}))
+	r.Post("/temperature", newHandler(client))
+
+	srv = httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv, exporter, forwarded
+}
+
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string, parent trace.SpanID) tracetest.SpanStub {
+	t.Helper()
+	for _, s := range spans {
+		if s.Name == name && s.Parent.SpanID() == parent {
+			return s
+		}
+	}
+	t.Fatalf("Expected a span %s with parent %s, but got %v", name, parent, spans.Snapshots())
+	return tracetest.SpanStub{}
+}
+
+func attributeOf(s tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestHandlerRecordsServiceBFailure(t *testing.T) {
+	srv, exporter, _ := newTestServer(t)
+
+	resp, err := http.Post(srv.URL+"/temperature", "application/json", strings.NewReader(`{"cep":"99999999"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, but got %d", resp.StatusCode)
+	}
+
+	spans := exporter.GetSpans()
+
+	server := findSpan(t, spans, "POST /temperature", trace.SpanID{})
+	if server.Status.Code != codes.Error {
+		t.Errorf("Expected the server span to have status Error, but got %s", server.Status.Code)
+	}
+
+	var body errorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Expected a JSON error body, but got %v", err)
+	}
+	traceID := server.SpanContext.TraceID().String()
+	if body.TraceID != traceID {
+		t.Errorf("Expected trace_id %s in the error body, but got %+v", traceID, body)
+	}
+	if got := resp.Header.Get("X-Trace-Id"); got != traceID {
+		t.Errorf("Expected X-Trace-Id %s, but got %s", traceID, got)
+	}
+	if cep := attributeOf(server, "address.cep").AsString(); cep != "99999999" {
+		t.Errorf("Expected address.cep 99999999, but got %s", cep)
+	}
+
+	call := findSpan(t, spans, "GetTemperatureSpan", server.SpanContext.SpanID())
+	if call.Status.Code != codes.Error {
+		t.Errorf("Expected GetTemperatureSpan to have status Error, but got %s", call.Status.Code)
+	}
+	if category := attributeOf(call, "error.category").AsString(); category != "upstream_status" {
+		t.Errorf("Expected error.category upstream_status, but got %s", category)
+	}
+	if status := attributeOf(call, "upstream.status_code").AsInt64(); status != http.StatusNotFound {
+		t.Errorf("Expected upstream.status_code 404, but got %d", status)
+	}
+
+	client := findSpan(t, spans, "GET", call.SpanContext.SpanID())
+	if client.SpanKind != trace.SpanKindClient {
+		t.Errorf("Expected a client span for the call to Service B, but got %s", client.SpanKind)
+	}
+}
+
+func TestHandlerRecordsInvalidCep(t *testing.T) {
+	srv, exporter, _ := newTestServer(t)
+
+	resp, err := http.Post(srv.URL+"/temperature", "application/json", strings.NewReader(`{"cep":"123"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, but got %d", resp.StatusCode)
+	}
+
+	server := findSpan(t, exporter.GetSpans(), "POST /temperature", trace.SpanID{})
+	if server.Status.Code != codes.Error {
+		t.Errorf("Expected the server span to have status Error, but got %s", server.Status.Code)
+	}
+	if category := attributeOf(server, "error.category").AsString(); category != "invalid_cep" {
+		t.Errorf("Expected error.category invalid_cep, but got %s", category)
+	}
+	if len(server.Events) == 0 || server.Events[0].Name != "exception" {
+		t.Errorf("Expected the server span to record an exception event")
+	}
+}
+
+func TestHandlerForwardsB3ContextToServiceB(t *testing.T) {
+	srv, _, forwarded := newTestServer(t)
+	propagator, _ := telemetry.NewPropagator([]string{"tracecontext", "b3"})
+	otel.SetTextMapPropagator(propagator)
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/temperature", strings.NewReader(`{"cep":"01001000"}`))
+	req.Header.Set("b3", "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+
+	for _, header := range []string{"traceparent", "b3"} {
+		if !strings.Contains(forwarded.Get(header), "80f198ee56343ba864fe8b2a57d3eff7") {
+			t.Errorf("Expected Service B to receive the caller's trace in %s, but got %q", header, forwarded.Get(header))
+		}
+	}
+}
+
+func TestHandlerForwardsRequestMetadataAsBaggage(t *testing.T) {
+	srv, exporter, forwarded := newTestServer(t)
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/temperature", strings.NewReader(`{"cep":"01001000"}`))
+	req.Header.Set("X-Tenant-Id", "acme")
+	req.Header.Set("X-Client-App", "mobile")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+
+	for _, member := range []string{"tenant.id=acme", "client.app=mobile"} {
+		if !strings.Contains(forwarded.Get("baggage"), member) {
+			t.Errorf("Expected Service B to receive %s in the baggage, but got %q", member, forwarded.Get("baggage"))
+		}
+	}
+
+	server := findSpan(t, exporter.GetSpans(), "POST /temperature", trace.SpanID{})
+	if tenant := attributeOf(server, "tenant.id").AsString(); tenant != "acme" {
+		t.Errorf("Expected the server span to have tenant.id acme, but got %q", tenant)
+	}
+}
+
+func TestHandlerForwardsRemainingDeadlineToServiceB(t *testing.T) {
+	srv, _, forwarded := newTestServer(t)
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/temperature", strings.NewReader(`{"cep":"01001000"}`))
+	req.Header.Set(deadline.Header, "1500")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+
+	ms, err := strconv.Atoi(forwarded.Get(deadline.Header))
+	if err != nil || ms <= 0 || ms > 1500 {
+		t.Errorf("Expected Service B to receive what is left of 1500ms, but got %q", forwarded.Get(deadline.Header))
+	}
+}
+
+func TestHandlerAnswers503WhenServiceBBreakerIsOpen(t *testing.T) {
+	calls := 0
+	serviceB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer serviceB.Close()
+
+	u, _ := url.Parse(serviceB.URL)
+	port, _ := strconv.Atoi(u.Port())
+	client, err := newServiceBClient(&configs.Config{
+		ServiceBHost:            "http://" + u.Hostname(),
+		ServiceBPort:            port,
+		BreakerFailureThreshold: 2,
+		BreakerOpenTimeout:      time.Minute,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	srv := httptest.NewServer(newHandler(client))
+	defer srv.Close()
+
+	var resp *http.Response
+	for range 3 {
+		resp, err = http.Post(srv.URL, "application/json", strings.NewReader(`{"cep":"01001000"}`))
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		resp.Body.Close()
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, but got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "60" {
+		t.Errorf("Expected Retry-After 60, but got %q", got)
+	}
+	if calls != 2 {
+		t.Errorf("Expected Service B to be called twice before the breaker opened, but got %d calls", calls)
+	}
+}
+
+func TestHandlerRateLimitsEachClient(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	limiter := newRateLimiter(&configs.Config{RateLimitClientRate: 0.1, RateLimitClientBurst: 1})
+	r := chi.NewRouter()
+	r.Use(telemetry.NewServerMiddleware("service-a", routePattern))
+	r.With(limiter.Middleware(clientKey)).Post("/temperature", func(w http.ResponseWriter, r *http.Request) {})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	post := func(apiKey string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/temperature", strings.NewReader(`{"cep":"01001000"}`))
+		req.Header.Set("X-API-Key", apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := post("a"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the first request to pass, but got %d", resp.StatusCode)
+	}
+	resp := post("a")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, but got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" || resp.Header.Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected Retry-After and RateLimit-Remaining 0, but got %q and %q", resp.Header.Get("Retry-After"), resp.Header.Get("RateLimit-Remaining"))
+	}
+	if resp := post("b"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected another API key to have its own limit, but got %d", resp.StatusCode)
+	}
+
+	spans := exporter.GetSpans()
+	if got := attributeOf(spans[1], "rate_limit.scope").AsString(); got != "client" {
+		t.Errorf("Expected the rejected span to carry rate_limit.scope client, but got %q", got)
+	}
+	if spans[1].Status.Code != codes.Error {
+		t.Errorf("Expected the rejected span to be an error, but got %v", spans[1].Status.Code)
+	}
+}
+
+func TestHandlerAuthenticatesClientsAndSignsCallsToServiceB(t *testing.T) {
+	var authorization string
+	serviceB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(`{"temp_c":20}`))
+	}))
+	defer serviceB.Close()
+
+	u, _ := url.Parse(serviceB.URL)
+	port, _ := strconv.Atoi(u.Port())
+	client, err := newServiceBClient(&configs.Config{
+		ServiceBHost:       "http://" + u.Hostname(),
+		ServiceBPort:       port,
+		ServiceTokenSecret: "secret",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	keys, _ := auth.LoadKeys([]string{"mobile=abc"}, "")
+	r := chi.NewRouter()
+	r.With(keys.Middleware).Post("/temperature", newHandler(client))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	post := func(apiKey string) int {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/temperature", strings.NewReader(`{"cep":"01001000"}`))
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := post("wrong"); status != http.StatusUnauthorized || authorization != "" {
+		t.Errorf("Expected 401 without calling Service B, but got %d", status)
+	}
+	if status := post("abc"); status != http.StatusOK {
+		t.Fatalf("Expected status 200, but got %d", status)
+	}
+	token, _ := strings.CutPrefix(authorization, "Bearer ")
+	claims, err := auth.NewVerifier("service-b", []string{"service-a"}, []byte("secret")).Verify(token)
+	if err != nil {
+		t.Fatalf("Expected Service B to receive a valid token, but got %v", err)
+	}
+	if claims.Subject != "mobile" {
+		t.Errorf("Expected the token to be on behalf of mobile, but got %q", claims.Subject)
+	}
+}
+
+func TestAdminRouterRequiresAdminKey(t *testing.T) {
+	keys, err := auth.LoadKeys([]string{"ops=admin-key"}, "")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	r := chi.NewRouter()
+	r.Mount("/admin", newAdminRouter(keys, faults.New()))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	get := func(key string) int {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/admin/faults", nil)
+		if key != "" {
+			req.Header.Set(auth.APIKeyHeader, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := get(""); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a key, but got %d", status)
+	}
+	if status := get("client-key"); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with a client key, but got %d", status)
+	}
+	if status := get("admin-key"); status != http.StatusOK {
+		t.Errorf("Expected status 200 with the admin key, but got %d", status)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// metadataHeaders maps the client identification headers accepted by
+// ServiceA to the baggage keys that carry them to ServiceB.
+var metadataHeaders = []struct {
+	header string
+	key    string
+}{
+	{"X-Tenant-Id", "tenant.id"},
+	{"X-Client-App", "client.app"},
+	{"X-Request-Origin", "request.origin"},
+}
+
+// newRequestMetadataMiddleware puts the client identifiers sent in
+// metadataHeaders into the W3C baggage of the request, so the spans, log
+// records and metrics of both services are tagged with the baggageKeys among
+// them. It runs inside the telemetry middleware, whose server span started
+// before the baggage existed and is therefore tagged here.
+func newRequestMetadataMiddleware(baggageKeys []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			b := baggage.FromContext(ctx)
+
+			for _, h := range metadataHeaders {
+				value := r.Header.Get(h.header)
+				if value == "" {
+					continue
+				}
+				m, err := baggage.NewMemberRaw(h.key, value)
+				if err == nil {
+					b, err = b.SetMember(m)
+				}
+				if err != nil {
+					slog.WarnContext(ctx, "Ignoring invalid request metadata", "header", h.header, "error", err)
+				}
+			}
+
+			ctx = baggage.ContextWithBaggage(ctx, b)
+			trace.SpanFromContext(ctx).SetAttributes(telemetry.BaggageAttributes(ctx, baggageKeys)...)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+
+	"github.com/EnnioSimoes/2-Observabilidade/ServiceA/configs"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/auth"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/ratelimit"
+)
+
+// newRateLimiter limits the requests of each client to
+// RATE_LIMIT_CLIENT_RATE per second, with the RATE_LIMIT_GLOBAL_RATE ceiling
+// shared by all of them.
+func newRateLimiter(config *configs.Config) *ratelimit.Limiter {
+	return ratelimit.NewLimiter("service-a",
+		ratelimit.Limit{Rate: config.RateLimitClientRate, Burst: config.RateLimitClientBurst},
+		ratelimit.Limit{Rate: config.RateLimitGlobalRate, Burst: config.RateLimitGlobalBurst},
+	)
+}
+
+// clientKey identifies the client of r by its authenticated principal. With
+// authentication off it falls back to the API key, hashed so the key is never
+// kept in memory as is, and then to the IP address.
+func clientKey(r *http.Request) string {
+	if principal := auth.Principal(r.Context()); principal != "" {
+		return "principal:" + principal
+	}
+	if key := r.Header.Get(auth.APIKeyHeader); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:8])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
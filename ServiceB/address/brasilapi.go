@@ -0,0 +1,61 @@
+package address
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const (
+	BrasilApiName = "brasilapi"
+
+	brasilApiBaseURL = "https://brasilapi.com.br"
+)
+
+type brasilApiCep struct {
+	Cep          string `json:"cep"`
+	State        string `json:"state"`
+	City         string `json:"city"`
+	Neighborhood string `json:"neighborhood"`
+	Street       string `json:"street"`
+}
+
+// BrasilApiProvider looks CEPs up on https://brasilapi.com.br (CEP v1).
+type BrasilApiProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewBrasilApiProvider(baseURL string, client *http.Client) *BrasilApiProvider {
+	if baseURL == "" {
+		baseURL = brasilApiBaseURL
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &BrasilApiProvider{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+func (p *BrasilApiProvider) Name() string {
+	return BrasilApiName
+}
+
+func (p *BrasilApiProvider) GetCep(cep string, ctx context.Context) (*Address, error) {
+	var c brasilApiCep
+	status, err := getJSON(ctx, p.client, p.baseURL+"/api/cep/v1/"+cep, &c)
+	if status == http.StatusNotFound {
+		return &Address{Provider: BrasilApiName}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Address{
+		Cep:          c.Cep,
+		Street:       c.Street,
+		Neighborhood: c.Neighborhood,
+		City:         c.City,
+		State:        c.State,
+		Provider:     BrasilApiName,
+	}, nil
+}
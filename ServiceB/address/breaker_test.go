@@ -0,0 +1,66 @@
+package address
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/breaker"
+)
+
+func TestBreakerProviderSkipsFailingProvider(t *testing.T) {
+	calls := 0
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(failing.Close)
+
+	b := breaker.New("test", ViaCepName, breaker.Settings{FailureThreshold: 2, OpenTimeout: time.Minute})
+	f := NewFallbackProvider(0,
+		NewBreakerProvider(NewViaCepProvider(failing.URL, nil), b),
+		NewOpenCepProvider(newOpenCepStandIn(t).URL, nil),
+	)
+
+	for range 5 {
+		addr, err := f.GetCep("01001000", context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if addr.Provider != OpenCepName {
+			t.Errorf("Expected Provider to be %s, but got %s", OpenCepName, addr.Provider)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected the open breaker to stop calling the failing provider after 2 calls, but it was called %d times", calls)
+	}
+	if b.State() != breaker.Open {
+		t.Errorf("Expected the breaker to be open, but got %s", b.State())
+	}
+}
+
+func TestBreakerProviderIgnoresUnknownCeps(t *testing.T) {
+	b := breaker.New("test", ViaCepName, breaker.Settings{FailureThreshold: 1})
+	p := NewBreakerProvider(NewViaCepProvider(newViaCepStandIn(t).URL, nil), b)
+
+	for range 3 {
+		if _, err := p.GetCep("99999999", context.Background()); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+	}
+	if b.State() != breaker.Closed {
+		t.Errorf("Expected unknown CEPs not to open the breaker, but got %s", b.State())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.GetCep("01001000", ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, but got %v", err)
+	}
+	if b.State() != breaker.Closed {
+		t.Errorf("Expected a canceled lookup not to open the breaker, but got %s", b.State())
+	}
+}
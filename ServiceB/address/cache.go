@@ -0,0 +1,78 @@
+package address
+
+import (
+	"context"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/ServiceB/cache"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CachedProvider keeps CEP lookups in memory, since the mapping from CEP to
+// city practically never changes. "Not found" answers are cached as well, for
+// negativeTTL; errors are never cached.
+type CachedProvider struct {
+	next        AddressProvider
+	entries     *cache.Cache[string, Address]
+	ttl         time.Duration
+	negativeTTL time.Duration
+	lookups     metric.Int64Counter
+}
+
+// NewCachedProvider puts a cache of at most size entries in front of next.
+func NewCachedProvider(next AddressProvider, size int, ttl time.Duration, negativeTTL time.Duration) *CachedProvider {
+	lookups, _ := otel.Meter("service-b").Int64Counter("address.cache.lookups",
+		metric.WithDescription("CEP lookups served by the address cache, by result (hit or miss)."),
+	)
+	return &CachedProvider{
+		next:        next,
+		entries:     cache.New[string, Address](size),
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		lookups:     lookups,
+	}
+}
+
+func (c *CachedProvider) Name() string {
+	return c.next.Name()
+}
+
+func (c *CachedProvider) GetCep(cep string, ctx context.Context) (*Address, error) {
+	span := trace.SpanFromContext(ctx)
+
+	if addr, ok := c.entries.Get(cep); ok {
+		c.record(ctx, span, "hit")
+		return &addr, nil
+	}
+	c.record(ctx, span, "miss")
+
+	addr, err := c.next.GetCep(cep, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := c.ttl
+	if addr.Cep == "" {
+		ttl = c.negativeTTL
+	}
+	c.entries.Set(cep, *addr, ttl)
+	return addr, nil
+}
+
+// Forget removes a single CEP from the cache and reports whether it was cached.
+func (c *CachedProvider) Forget(cep string) bool {
+	return c.entries.Delete(cep)
+}
+
+// Purge empties the cache and returns how many entries were removed.
+func (c *CachedProvider) Purge() int {
+	return c.entries.Purge()
+}
+
+func (c *CachedProvider) record(ctx context.Context, span trace.Span, result string) {
+	span.SetAttributes(attribute.Bool("address.cache.hit", result == "hit"))
+	c.lookups.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+}
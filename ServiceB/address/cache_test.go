@@ -0,0 +1,77 @@
+package address
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type stubProvider struct {
+	calls int
+	addr  *Address
+	err   error
+}
+
+func (s *stubProvider) Name() string {
+	return "stub"
+}
+
+func (s *stubProvider) GetCep(cep string, ctx context.Context) (*Address, error) {
+	s.calls++
+	return s.addr, s.err
+}
+
+func TestCachedProviderServesHits(t *testing.T) {
+	stub := &stubProvider{addr: &Address{Cep: "01001000", City: "São Paulo"}}
+	c := NewCachedProvider(stub, 10, time.Hour, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		addr, err := c.GetCep("01001000", context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if addr.City != "São Paulo" {
+			t.Errorf("Expected City to be São Paulo, but got %s", addr.City)
+		}
+	}
+	if stub.calls != 1 {
+		t.Errorf("Expected 1 upstream call, but got %d", stub.calls)
+	}
+
+	if !c.Forget("01001000") {
+		t.Errorf("Expected Forget to report the CEP as cached")
+	}
+	c.GetCep("01001000", context.Background())
+	if stub.calls != 2 {
+		t.Errorf("Expected a new upstream call after Forget, but got %d calls", stub.calls)
+	}
+}
+
+func TestCachedProviderCachesNotFound(t *testing.T) {
+	stub := &stubProvider{addr: &Address{}}
+	c := NewCachedProvider(stub, 10, time.Hour, time.Minute)
+
+	c.GetCep("99999999", context.Background())
+	addr, _ := c.GetCep("99999999", context.Background())
+	if addr.Cep != "" {
+		t.Errorf("Expected empty Cep, but got %s", addr.Cep)
+	}
+	if stub.calls != 1 {
+		t.Errorf("Expected 1 upstream call, but got %d", stub.calls)
+	}
+}
+
+func TestCachedProviderDoesNotCacheErrors(t *testing.T) {
+	stub := &stubProvider{err: fmt.Errorf("upstream down")}
+	c := NewCachedProvider(stub, 10, time.Hour, time.Minute)
+
+	c.GetCep("01001000", context.Background())
+	c.GetCep("01001000", context.Background())
+	if stub.calls != 2 {
+		t.Errorf("Expected 2 upstream calls, but got %d", stub.calls)
+	}
+	if n := c.Purge(); n != 0 {
+		t.Errorf("Expected an empty cache, but purged %d entries", n)
+	}
+}
@@ -0,0 +1,35 @@
+package address
+
+import (
+	"context"
+
+	"github.com/EnnioSimoes/2-Observabilidade/ServiceB/coalesce"
+)
+
+// CoalescingProvider lets concurrent lookups of the same CEP share a single
+// upstream call.
+type CoalescingProvider struct {
+	next  AddressProvider
+	group coalesce.Group[string, *Address]
+}
+
+func NewCoalescingProvider(next AddressProvider) *CoalescingProvider {
+	return &CoalescingProvider{next: next}
+}
+
+func (c *CoalescingProvider) Name() string {
+	return c.next.Name()
+}
+
+func (c *CoalescingProvider) GetCep(cep string, ctx context.Context) (*Address, error) {
+	addr, err := c.group.Do(ctx, cep, "SharedGetLocationByCepSpan", func(ctx context.Context) (*Address, error) {
+		return c.next.GetCep(cep, ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Every caller gets its own copy of the shared result.
+	shared := *addr
+	return &shared, nil
+}
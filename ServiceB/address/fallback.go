@@ -0,0 +1,97 @@
+package address
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/breaker"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// FallbackProvider tries each provider in priority order and returns the first
+// answer, so an unavailable or slow upstream does not fail the lookup. A
+// "not found" answer is authoritative and stops the chain.
+type FallbackProvider struct {
+	providers []AddressProvider
+	timeout   time.Duration
+	upstreams *telemetry.UpstreamRecorder
+}
+
+// NewFallbackProvider chains providers in the given order. A positive timeout
+// bounds every single attempt, within what is left of the request deadline;
+// once the deadline is spent, the remaining providers are skipped.
+func NewFallbackProvider(timeout time.Duration, providers ...AddressProvider) *FallbackProvider {
+	return &FallbackProvider{providers: providers, timeout: timeout, upstreams: telemetry.NewUpstreamRecorder("service-b")}
+}
+
+func (f *FallbackProvider) Name() string {
+	return "fallback"
+}
+
+func (f *FallbackProvider) GetCep(cep string, ctx context.Context) (*Address, error) {
+	// Span da consulta inteira; cada tentativa de provedor vira um span filho
+	ctx, span := otel.Tracer("service-b").Start(ctx, "GetLocationByCepSpan")
+	defer span.End()
+	span.SetAttributes(attribute.String("address.cep", cep))
+
+	if _, err := checkCep(cep); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+
+	var errs []error
+	for i, p := range f.providers {
+		if err := ctx.Err(); err != nil {
+			// O prazo da requisição acabou: não adianta tentar os próximos provedores
+			errs = append(errs, err)
+			break
+		}
+		addr, err := f.attempt(ctx, p, i+1, cep)
+		if err == nil {
+			span.SetAttributes(attribute.String("address.provider", p.Name()))
+			return addr, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+
+	err := fmt.Errorf("no address provider configured")
+	if openErr := breaker.Soonest(errs); openErr != nil {
+		// Todos os breakers estão abertos: o handler responde 503 com Retry-After
+		err = fmt.Errorf("all address providers are unavailable: %w", openErr)
+	} else if len(errs) > 0 {
+		err = telemetry.WithCategory(fmt.Errorf("all address providers failed: %w", errors.Join(errs...)), "upstream_unavailable")
+	}
+	telemetry.RecordError(span, err)
+	return nil, err
+}
+
+// attempt queries a single provider inside its own child span.
+func (f *FallbackProvider) attempt(ctx context.Context, p AddressProvider, n int, cep string) (*Address, error) {
+	ctx, span := otel.Tracer("service-b").Start(ctx, "GetLocationByCep "+p.Name())
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("address.provider", p.Name()),
+		attribute.Int("address.attempt", n),
+	)
+
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	addr, err := p.GetCep(cep, ctx)
+	f.upstreams.Record(ctx, p.Name(), start, err)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Bool("address.found", addr.Cep != ""))
+	return addr, nil
+}
@@ -0,0 +1,146 @@
+package address
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newViaCepStandIn(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ws/01001000/json/":
+			w.Write([]byte(`{"cep":"01001-000","logradouro":"Praça da Sé","bairro":"Sé","localidade":"São Paulo","uf":"SP"}`))
+		default:
+			w.Write([]byte(`{"erro":"true"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newBrasilApiStandIn(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/cep/v1/01001000" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"cep":"01001000","state":"SP","city":"São Paulo","neighborhood":"Sé","street":"Praça da Sé"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newOpenCepStandIn(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/01001000" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"cep":"01001-000","logradouro":"Praça da Sé","bairro":"Sé","localidade":"São Paulo","uf":"SP"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newFailingStandIn(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProvidersNormalizeAddress(t *testing.T) {
+	providers := []AddressProvider{
+		NewViaCepProvider(newViaCepStandIn(t).URL, nil),
+		NewBrasilApiProvider(newBrasilApiStandIn(t).URL, nil),
+		NewOpenCepProvider(newOpenCepStandIn(t).URL, nil),
+	}
+
+	for _, p := range providers {
+		addr, err := p.GetCep("01001000", context.Background())
+		if err != nil {
+			t.Fatalf("%s: expected no error, but got %v", p.Name(), err)
+		}
+		if addr.Cep != "01001000" {
+			t.Errorf("%s: expected Cep to be 01001000, but got %s", p.Name(), addr.Cep)
+		}
+		if addr.City != "São Paulo" {
+			t.Errorf("%s: expected City to be São Paulo, but got %s", p.Name(), addr.City)
+		}
+		if addr.Provider != p.Name() {
+			t.Errorf("%s: expected Provider to be %s, but got %s", p.Name(), p.Name(), addr.Provider)
+		}
+
+		addr, err = p.GetCep("99999999", context.Background())
+		if err != nil {
+			t.Fatalf("%s: expected not found to return no error, but got %v", p.Name(), err)
+		}
+		if addr.Cep != "" {
+			t.Errorf("%s: expected empty Cep for unknown zipcode, but got %s", p.Name(), addr.Cep)
+		}
+	}
+}
+
+func TestFallbackProviderSkipsFailingProviders(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	t.Cleanup(slow.Close)
+
+	f := NewFallbackProvider(50*time.Millisecond,
+		NewViaCepProvider(slow.URL, nil),
+		NewBrasilApiProvider(newFailingStandIn(t).URL, nil),
+		NewOpenCepProvider(newOpenCepStandIn(t).URL, nil),
+	)
+
+	addr, err := f.GetCep("01001000", context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if addr.Provider != OpenCepName {
+		t.Errorf("Expected Provider to be %s, but got %s", OpenCepName, addr.Provider)
+	}
+}
+
+func TestFallbackProviderStopsOnNotFound(t *testing.T) {
+	calls := 0
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	t.Cleanup(next.Close)
+
+	f := NewFallbackProvider(0,
+		NewViaCepProvider(newViaCepStandIn(t).URL, nil),
+		NewOpenCepProvider(next.URL, nil),
+	)
+
+	addr, err := f.GetCep("99999999", context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if addr.Cep != "" {
+		t.Errorf("Expected empty Cep, but got %s", addr.Cep)
+	}
+	if calls != 0 {
+		t.Errorf("Expected the next provider not to be called, but it was called %d times", calls)
+	}
+}
+
+func TestFallbackProviderAllFailing(t *testing.T) {
+	f := NewFallbackProvider(0,
+		NewViaCepProvider(newFailingStandIn(t).URL, nil),
+		NewBrasilApiProvider(newFailingStandIn(t).URL, nil),
+	)
+
+	if _, err := f.GetCep("01001000", context.Background()); err == nil {
+		t.Errorf("Expected an error when every provider fails, but got none")
+	}
+}
@@ -2,75 +2,87 @@ package address
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"time"
 
-	"go.opentelemetry.io/otel"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
 )
 
-type ViaCep struct {
-	Cep         string `json:"cep"`
-	Logradouro  string `json:"logradouro"`
-	Complemento string `json:"complemento"`
-	Unidade     string `json:"unidade"`
-	Bairro      string `json:"bairro"`
-	Localidade  string `json:"localidade"`
-	Uf          string `json:"uf"`
-	Estado      string `json:"estado"`
-	Regiao      string `json:"regiao"`
-	Ibge        string `json:"ibge"`
-	Gia         string `json:"gia"`
-	Ddd         string `json:"ddd"`
-	Siafi       string `json:"siafi"`
-}
+var (
+	// ErrInvalidCep is returned for zipcodes that are not 8 characters long.
+	ErrInvalidCep = telemetry.WithCategory(errors.New("invalid zipcode"), "invalid_cep")
 
-func GetCep(cep string, ctx context.Context) (*ViaCep, error) {
-	// Intrumenta o span para a chamada interna
-	// Pega o tracer novamente (ou poderia ser passado como argumento)
-	tracer := otel.Tracer("service-b")
+	// ErrCepNotFound describes a zipcode no provider knows about.
+	ErrCepNotFound = telemetry.WithCategory(errors.New("can not find zipcode"), "not_found")
+)
 
-	// Inicia um span filho, pois estamos usando o contexto do `helloHandlerSpan`
-	_, span := tracer.Start(ctx, "GetLocationByCepSpan")
-	defer span.End()
+// Address is the provider-neutral result of a CEP lookup. An Address with an
+// empty Cep means the provider answered but does not know the zipcode.
+type Address struct {
+	Cep          string `json:"cep"`
+	Street       string `json:"street"`
+	Neighborhood string `json:"neighborhood"`
+	City         string `json:"city"`
+	State        string `json:"state"`
+	Provider     string `json:"provider"`
+}
 
-	time.Sleep(1 * time.Second) // Simula algum processamento
+// AddressProvider resolves a CEP into an Address using a single upstream API.
+type AddressProvider interface {
+	Name() string
+	GetCep(cep string, ctx context.Context) (*Address, error)
+}
 
-	// Desabilitar a verificação do certificado SSL
-	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+// NewProvider builds the provider registered under name. An empty baseURL
+// points the provider at its public endpoint, and a nil client falls back to
+// http.DefaultClient.
+func NewProvider(name string, baseURL string, client *http.Client) (AddressProvider, error) {
+	switch name {
+	case ViaCepName:
+		return NewViaCepProvider(baseURL, client), nil
+	case BrasilApiName:
+		return NewBrasilApiProvider(baseURL, client), nil
+	case OpenCepName:
+		return NewOpenCepProvider(baseURL, client), nil
+	}
+	return nil, fmt.Errorf("unknown address provider: %s", name)
+}
 
-	_, err := checkCep(cep)
+func checkCep(cep string) (bool, error) {
+	if len(cep) != 8 {
+		return false, ErrInvalidCep
+	}
+	return true, nil
+}
+
+// getJSON performs a GET against url and decodes a 200 response into v. The
+// returned status code lets callers tell "not found" apart from failures.
+func getJSON(ctx context.Context, client *http.Client, url string, v any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		fmt.Errorf("Error: %w", err)
+		return 0, fmt.Errorf("error creating request: %w", err)
 	}
 
-	resp, error := http.Get("https://viacep.com.br/ws/" + cep + "/json/")
-	if error != nil {
-		return nil, error
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
 	}
 	defer resp.Body.Close()
 
-	body, error := io.ReadAll(resp.Body)
-	if error != nil {
-		return nil, error
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, &telemetry.StatusError{StatusCode: resp.StatusCode, Host: req.URL.Host}
 	}
-	// fmt.Println("Address resp: ", string(body))
 
-	var c ViaCep
-	error = json.Unmarshal(body, &c)
-	if error != nil {
-		return nil, error
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
 	}
 
-	return &c, nil
-}
-
-func checkCep(cep string) (bool, error) {
-	if len(cep) != 8 {
-		return false, fmt.Errorf("invalid zipcode")
+	if err := json.Unmarshal(body, v); err != nil {
+		return resp.StatusCode, err
 	}
-	return true, nil
+	return resp.StatusCode, nil
 }
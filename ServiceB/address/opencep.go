@@ -0,0 +1,62 @@
+package address
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const (
+	OpenCepName = "opencep"
+
+	openCepBaseURL = "https://opencep.com"
+)
+
+type openCep struct {
+	Cep        string `json:"cep"`
+	Logradouro string `json:"logradouro"`
+	Bairro     string `json:"bairro"`
+	Localidade string `json:"localidade"`
+	Uf         string `json:"uf"`
+}
+
+// OpenCepProvider looks CEPs up on https://opencep.com or any service that
+// serves the same ViaCEP-like payload under /v1/{cep}.
+type OpenCepProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewOpenCepProvider(baseURL string, client *http.Client) *OpenCepProvider {
+	if baseURL == "" {
+		baseURL = openCepBaseURL
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &OpenCepProvider{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+func (p *OpenCepProvider) Name() string {
+	return OpenCepName
+}
+
+func (p *OpenCepProvider) GetCep(cep string, ctx context.Context) (*Address, error) {
+	var c openCep
+	status, err := getJSON(ctx, p.client, p.baseURL+"/v1/"+cep, &c)
+	if status == http.StatusNotFound {
+		return &Address{Provider: OpenCepName}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Address{
+		Cep:          strings.ReplaceAll(c.Cep, "-", ""),
+		Street:       c.Logradouro,
+		Neighborhood: c.Bairro,
+		City:         c.Localidade,
+		State:        c.Uf,
+		Provider:     OpenCepName,
+	}, nil
+}
@@ -0,0 +1,71 @@
+package address
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const (
+	ViaCepName = "viacep"
+
+	viaCepBaseURL = "https://viacep.com.br"
+)
+
+type ViaCep struct {
+	Cep         string `json:"cep"`
+	Logradouro  string `json:"logradouro"`
+	Complemento string `json:"complemento"`
+	Unidade     string `json:"unidade"`
+	Bairro      string `json:"bairro"`
+	Localidade  string `json:"localidade"`
+	Uf          string `json:"uf"`
+	Estado      string `json:"estado"`
+	Regiao      string `json:"regiao"`
+	Ibge        string `json:"ibge"`
+	Gia         string `json:"gia"`
+	Ddd         string `json:"ddd"`
+	Siafi       string `json:"siafi"`
+}
+
+// ViaCepProvider looks CEPs up on https://viacep.com.br.
+type ViaCepProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewViaCepProvider(baseURL string, client *http.Client) *ViaCepProvider {
+	if baseURL == "" {
+		baseURL = viaCepBaseURL
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &ViaCepProvider{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+func (p *ViaCepProvider) Name() string {
+	return ViaCepName
+}
+
+func (p *ViaCepProvider) GetCep(cep string, ctx context.Context) (*Address, error) {
+	var c ViaCep
+	if _, err := getJSON(ctx, p.client, p.baseURL+"/ws/"+cep+"/json/", &c); err != nil {
+		return nil, err
+	}
+
+	// ViaCEP answers unknown zipcodes with 200 and {"erro": true}, which leaves
+	// every field empty.
+	if c.Cep == "" {
+		return &Address{Provider: ViaCepName}, nil
+	}
+
+	return &Address{
+		Cep:          strings.ReplaceAll(c.Cep, "-", ""),
+		Street:       c.Logradouro,
+		Neighborhood: c.Bairro,
+		City:         c.Localidade,
+		State:        c.Uf,
+		Provider:     ViaCepName,
+	}, nil
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[string, int](2)
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+
+	// Touch "a" so "b" becomes the least recently used entry.
+	c.Get("a")
+	c.Set("c", 3, time.Minute)
+
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected b to be evicted, but it is still cached")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected a to be 1, but got %d (found: %v)", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Expected Len to be 2, but got %d", c.Len())
+	}
+}
+
+func TestCacheExpiresEntries(t *testing.T) {
+	now := time.Now()
+	c := New[string, int](10)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1, time.Minute)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("Expected a to be cached")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected a to be expired, but it is still cached")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Expected expired entry to be removed, but Len is %d", c.Len())
+	}
+}
+
+func TestCacheDeleteAndPurge(t *testing.T) {
+	c := New[string, int](10)
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+	c.Set("c", 3, time.Minute)
+
+	if !c.Delete("a") {
+		t.Errorf("Expected Delete to report a as present")
+	}
+	if c.Delete("a") {
+		t.Errorf("Expected Delete to report a as absent the second time")
+	}
+	if n := c.Purge(); n != 2 {
+		t.Errorf("Expected Purge to remove 2 entries, but removed %d", n)
+	}
+	if c.Len() != 0 {
+		t.Errorf("Expected Len to be 0 after Purge, but got %d", c.Len())
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	c := New[string, int](0)
+	c.Set("a", 1, time.Minute)
+
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected a zero-sized cache to store nothing")
+	}
+}
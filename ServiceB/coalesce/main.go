@@ -0,0 +1,100 @@
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrPanicked is returned to the callers waiting on a call whose fn panicked;
+// the caller running it gets the panic itself.
+var ErrPanicked = errors.New("coalesced call panicked")
+
+type call[V any] struct {
+	done        chan struct{}
+	val         V
+	err         error
+	spanContext trace.SpanContext
+	waiters     int
+	// abandoned is set when fn failed after the context of the caller running
+	// it was done, so its error says nothing about the key.
+	abandoned bool
+}
+
+// Group deduplicates concurrent calls sharing the same key. The first caller
+// runs fn inside its own span; callers arriving while that call is in flight
+// wait for its result and link their span to the shared one, so traces still
+// show which upstream call served them. When the first caller gives up
+// mid-call, waiters whose own context is still alive run the call again
+// instead of inheriting its cancellation. The zero Group is ready to use.
+type Group[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+// Do runs fn once for every set of concurrent callers with the same key.
+// spanName names the span wrapping the shared call.
+func (g *Group[K, V]) Do(ctx context.Context, key K, spanName string, fn func(ctx context.Context) (V, error)) (V, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+
+	for c, ok := g.calls[key]; ok; c, ok = g.calls[key] {
+		c.waiters++
+		g.mu.Unlock()
+
+		span := trace.SpanFromContext(ctx)
+		span.AddLink(trace.Link{SpanContext: c.spanContext})
+		span.SetAttributes(attribute.Bool("coalesced", true))
+
+		select {
+		case <-c.done:
+			if !c.abandoned || ctx.Err() != nil {
+				return c.val, c.err
+			}
+			// Quem iniciou a chamada desistiu dela; este chamador ainda tem
+			// prazo, então tenta de novo com o próprio contexto
+			g.mu.Lock()
+		case <-ctx.Done():
+			var zero V
+			return zero, ctx.Err()
+		}
+	}
+
+	ctx, span := otel.Tracer("service-b").Start(ctx, spanName)
+	c := &call[V]{done: make(chan struct{}), spanContext: span.SpanContext()}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	// A limpeza roda mesmo se fn entrar em pânico, para não deixar os
+	// chamadores seguintes presos a uma chamada que nunca termina
+	panicked := true
+	defer func() {
+		if panicked {
+			c.err = ErrPanicked
+			telemetry.RecordError(span, c.err)
+		}
+
+		g.mu.Lock()
+		delete(g.calls, key)
+		span.SetAttributes(attribute.Int("coalesce.waiters", c.waiters))
+		g.mu.Unlock()
+
+		span.End()
+		close(c.done)
+	}()
+
+	c.val, c.err = fn(ctx)
+	panicked = false
+	if c.err != nil {
+		c.abandoned = ctx.Err() != nil
+		telemetry.RecordError(span, c.err)
+	}
+	return c.val, c.err
+}
@@ -0,0 +1,212 @@
+package coalesce
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func waitForWaiters(t *testing.T, g *Group[string, int], key string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		g.mu.Lock()
+		c, ok := g.calls[key]
+		ready := ok && c.waiters == n
+		g.mu.Unlock()
+		if ready {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d waiters", n)
+}
+
+func TestGroupSharesConcurrentCalls(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	otel.SetTracerProvider(tp)
+	tracer := tp.Tracer("test")
+
+	var g Group[string, int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+	calls := 0
+
+	fn := func(ctx context.Context) (int, error) {
+		calls++
+		close(started)
+		<-release
+		return 42, nil
+	}
+
+	const waiters = 3
+	results := make([]int, waiters+1)
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _ = g.Do(context.Background(), "01001000", "SharedSpan", fn)
+	}()
+	<-started
+
+	for i := 1; i <= waiters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, span := tracer.Start(context.Background(), "WaiterSpan")
+			defer span.End()
+			results[i], _ = g.Do(ctx, "01001000", "SharedSpan", fn)
+		}(i)
+	}
+	waitForWaiters(t, &g, "01001000", waiters)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("Expected fn to run once, but it ran %d times", calls)
+	}
+	for i, r := range results {
+		if r != 42 {
+			t.Errorf("Expected result %d to be 42, but got %d", i, r)
+		}
+	}
+
+	var shared sdktrace.ReadOnlySpan
+	var linked int
+	for _, s := range sr.Ended() {
+		switch s.Name() {
+		case "SharedSpan":
+			shared = s
+		case "WaiterSpan":
+			linked += len(s.Links())
+		}
+	}
+	if shared == nil {
+		t.Fatalf("Expected the shared call to be recorded as SharedSpan")
+	}
+	if linked != waiters {
+		t.Errorf("Expected %d links from waiting spans, but got %d", waiters, linked)
+	}
+	for _, s := range sr.Ended() {
+		for _, l := range s.Links() {
+			if l.SpanContext.SpanID() != shared.SpanContext().SpanID() {
+				t.Errorf("Expected links to point to the shared span")
+			}
+		}
+	}
+}
+
+func TestGroupRunsSequentialCallsAgain(t *testing.T) {
+	var g Group[string, int]
+	calls := 0
+	fn := func(ctx context.Context) (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	g.Do(context.Background(), "a", "SharedSpan", fn)
+	g.Do(context.Background(), "a", "SharedSpan", fn)
+	if calls != 2 {
+		t.Errorf("Expected fn to run for each sequential call, but it ran %d times", calls)
+	}
+}
+
+func TestGroupRetriesForWaitersWhenTheLeaderIsCanceled(t *testing.T) {
+	var g Group[string, int]
+	started := make(chan struct{})
+	calls := 0
+
+	fn := func(ctx context.Context) (int, error) {
+		calls++
+		if calls == 1 {
+			close(started)
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}
+		return 42, nil
+	}
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	var leaderErr error
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		_, leaderErr = g.Do(leaderCtx, "01001000", "SharedSpan", fn)
+	}()
+	<-started
+
+	var got int
+	var err error
+	waiterDone := make(chan struct{})
+	go func() {
+		defer close(waiterDone)
+		got, err = g.Do(context.Background(), "01001000", "SharedSpan", fn)
+	}()
+	waitForWaiters(t, &g, "01001000", 1)
+	cancelLeader()
+	<-leaderDone
+	<-waiterDone
+
+	if leaderErr != context.Canceled {
+		t.Errorf("Expected the leader to get context.Canceled, but got %v", leaderErr)
+	}
+	if err != nil || got != 42 {
+		t.Errorf("Expected the waiter to get 42, but got %d and %v", got, err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected fn to run again for the waiter, but it ran %d times", calls)
+	}
+}
+
+func TestGroupReleasesWaitersWhenFnPanics(t *testing.T) {
+	var g Group[string, int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var recovered any
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		defer func() { recovered = recover() }()
+		g.Do(context.Background(), "01001000", "SharedSpan", func(ctx context.Context) (int, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	var err error
+	waiterDone := make(chan struct{})
+	go func() {
+		defer close(waiterDone)
+		_, err = g.Do(context.Background(), "01001000", "SharedSpan", func(ctx context.Context) (int, error) {
+			return 42, nil
+		})
+	}()
+	waitForWaiters(t, &g, "01001000", 1)
+	close(release)
+	<-leaderDone
+	<-waiterDone
+
+	if recovered != "boom" {
+		t.Errorf("Expected the leader to panic with boom, but got %v", recovered)
+	}
+	if err != ErrPanicked {
+		t.Errorf("Expected the waiter to get ErrPanicked, but got %v", err)
+	}
+
+	got, err := g.Do(context.Background(), "01001000", "SharedSpan", func(ctx context.Context) (int, error) {
+		return 42, nil
+	})
+	if err != nil || got != 42 {
+		t.Errorf("Expected a later call to run fn again and get 42, but got %d and %v", got, err)
+	}
+}
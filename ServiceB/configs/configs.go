@@ -2,12 +2,110 @@ package configs
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	WeatherapiKey string `mapstructure:"WEATHER_API_KEY"`
+	// Chaves da WeatherAPI, separadas por vírgula. São usadas em rodízio, e
+	// uma chave que responde erro de cota fica de fora por
+	// WEATHER_API_KEY_COOLDOWN.
+	WeatherapiKeys        []string      `mapstructure:"WEATHER_API_KEY"`
+	WeatherapiKeyCooldown time.Duration `mapstructure:"WEATHER_API_KEY_COOLDOWN"`
+
+	// Ordem de prioridade dos provedores de CEP, separados por vírgula.
+	AddressProviders       []string      `mapstructure:"ADDRESS_PROVIDERS"`
+	AddressProviderTimeout time.Duration `mapstructure:"ADDRESS_PROVIDER_TIMEOUT"`
+	ViaCepURL              string        `mapstructure:"VIACEP_URL"`
+	BrasilApiURL           string        `mapstructure:"BRASILAPI_URL"`
+	OpenCepURL             string        `mapstructure:"OPENCEP_URL"`
+
+	// Cache de CEPs. Tamanho 0 desabilita o cache.
+	AddressCacheSize        int           `mapstructure:"ADDRESS_CACHE_SIZE"`
+	AddressCacheTTL         time.Duration `mapstructure:"ADDRESS_CACHE_TTL"`
+	AddressCacheNegativeTTL time.Duration `mapstructure:"ADDRESS_CACHE_NEGATIVE_TTL"`
+
+	// Ordem de prioridade dos provedores de clima, separados por vírgula.
+	WeatherProviders       []string      `mapstructure:"WEATHER_PROVIDERS"`
+	WeatherProviderTimeout time.Duration `mapstructure:"WEATHER_PROVIDER_TIMEOUT"`
+	WeatherApiURL          string        `mapstructure:"WEATHERAPI_URL"`
+	OpenMeteoURL           string        `mapstructure:"OPENMETEO_URL"`
+	OpenMeteoGeocodingURL  string        `mapstructure:"OPENMETEO_GEOCODING_URL"`
+
+	// Clientes HTTP dos provedores. Cada provedor tem o seu próprio cliente e
+	// pool de conexões. HTTP_CLIENT_INSECURE lista, separados por vírgula, os
+	// provedores cujo certificado não é verificado (apenas para testes locais).
+	HTTPClientTimeout             time.Duration `mapstructure:"HTTP_CLIENT_TIMEOUT"`
+	HTTPClientDialTimeout         time.Duration `mapstructure:"HTTP_CLIENT_DIAL_TIMEOUT"`
+	HTTPClientTLSHandshakeTimeout time.Duration `mapstructure:"HTTP_CLIENT_TLS_HANDSHAKE_TIMEOUT"`
+	HTTPClientIdleConnTimeout     time.Duration `mapstructure:"HTTP_CLIENT_IDLE_CONN_TIMEOUT"`
+	HTTPClientMaxIdleConnsPerHost int           `mapstructure:"HTTP_CLIENT_MAX_IDLE_CONNS_PER_HOST"`
+	HTTPClientMaxConnsPerHost     int           `mapstructure:"HTTP_CLIENT_MAX_CONNS_PER_HOST"`
+	HTTPClientCAFile              string        `mapstructure:"HTTP_CLIENT_CA_FILE"`
+	HTTPClientInsecure            []string      `mapstructure:"HTTP_CLIENT_INSECURE"`
+
+	// Novas tentativas para erros de rede e os status listados, com espera
+	// exponencial a partir de RETRY_BASE_DELAY e até RETRY_MAX_DELAY.
+	// RETRY_MAX_ATTEMPTS inclui a primeira tentativa; 1 desliga as novas
+	// tentativas.
+	RetryMaxAttempts int           `mapstructure:"RETRY_MAX_ATTEMPTS"`
+	RetryBaseDelay   time.Duration `mapstructure:"RETRY_BASE_DELAY"`
+	RetryMaxDelay    time.Duration `mapstructure:"RETRY_MAX_DELAY"`
+	RetryJitter      float64       `mapstructure:"RETRY_JITTER"`
+	RetryStatusCodes []int         `mapstructure:"RETRY_STATUS_CODES"`
+
+	// Circuit breaker de cada upstream: abre após BREAKER_FAILURE_THRESHOLD
+	// falhas seguidas, fica aberto por BREAKER_OPEN_TIMEOUT e então deixa
+	// passar BREAKER_HALF_OPEN_REQUESTS chamadas de teste.
+	BreakerFailureThreshold int           `mapstructure:"BREAKER_FAILURE_THRESHOLD"`
+	BreakerOpenTimeout      time.Duration `mapstructure:"BREAKER_OPEN_TIMEOUT"`
+	BreakerHalfOpenRequests int           `mapstructure:"BREAKER_HALF_OPEN_REQUESTS"`
+
+	// Segredos HMAC aceitos no token de serviço, separados por vírgula (mais
+	// de um durante a troca do segredo), e os serviços que podem chamar o
+	// Serviço B. Sem segredo, a autenticação fica desligada.
+	ServiceTokenSecrets []string `mapstructure:"SERVICE_TOKEN_SECRET"`
+	ServiceTokenIssuers []string `mapstructure:"SERVICE_TOKEN_ISSUERS"`
+
+	// Chaves das rotas de administração (/admin), no formato nome=chave
+	// separado por vírgula e enviadas no cabeçalho X-API-Key. Sem chaves, as
+	// rotas de administração não existem.
+	AdminAPIKeys []string `mapstructure:"ADMIN_API_KEYS"`
+
+	// Limite de chamadas por segundo de cada upstream, no formato
+	// nome=taxa separado por vírgula (ex.: weatherapi=1,openmeteo=5).
+	// Upstreams fora da lista não são limitados.
+	UpstreamRateLimits []string `mapstructure:"UPSTREAM_RATE_LIMITS"`
+
+	// Cache de temperaturas por localidade. Tamanho 0 desabilita o cache.
+	WeatherCacheSize      int           `mapstructure:"WEATHER_CACHE_SIZE"`
+	WeatherCacheFreshness time.Duration `mapstructure:"WEATHER_CACHE_FRESHNESS"`
+
+	// Logs: nível (debug, info, warn, error), formato do console (text ou
+	// json) e se os registros também são escritos no console.
+	LogLevel   string `mapstructure:"LOG_LEVEL"`
+	LogFormat  string `mapstructure:"LOG_FORMAT"`
+	LogConsole bool   `mapstructure:"LOG_CONSOLE"`
+
+	// Chaves do baggage copiadas para spans, logs e métricas, separadas por
+	// vírgula.
+	BaggageKeys []string `mapstructure:"BAGGAGE_KEYS"`
+
+	// Prazo de cada requisição quando o cliente não envia X-Request-Timeout,
+	// e o maior prazo aceito do cliente.
+	RequestTimeout    time.Duration `mapstructure:"REQUEST_TIMEOUT"`
+	RequestTimeoutMax time.Duration `mapstructure:"REQUEST_TIMEOUT_MAX"`
+
+	// Porta do TemperatureService em gRPC, servido junto com a rota HTTP.
+	// 0 desliga o servidor gRPC.
+	GRPCPort int `mapstructure:"GRPC_PORT"`
+
+	// Injeção de falhas (latência, erros e timeouts) para testes. Desligada
+	// por padrão; as regras iniciais são um array JSON e podem ser trocadas
+	// em tempo de execução via /admin/faults.
+	FaultsEnabled bool   `mapstructure:"FAULTS_ENABLED"`
+	FaultsRules   string `mapstructure:"FAULTS_RULES"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -16,6 +114,54 @@ func LoadConfig() (*Config, error) {
 	viper.SetConfigName(".env")
 	viper.SetConfigType("env")
 
+	// Valores padrão também tornam as chaves visíveis para o AutomaticEnv.
+	viper.SetDefault("WEATHER_API_KEY", "")
+	viper.SetDefault("WEATHER_API_KEY_COOLDOWN", "1h")
+	viper.SetDefault("ADDRESS_PROVIDERS", "viacep,brasilapi,opencep")
+	viper.SetDefault("ADDRESS_PROVIDER_TIMEOUT", "2s")
+	viper.SetDefault("VIACEP_URL", "")
+	viper.SetDefault("BRASILAPI_URL", "")
+	viper.SetDefault("OPENCEP_URL", "")
+	viper.SetDefault("ADDRESS_CACHE_SIZE", 10000)
+	viper.SetDefault("ADDRESS_CACHE_TTL", "24h")
+	viper.SetDefault("ADDRESS_CACHE_NEGATIVE_TTL", "10m")
+	viper.SetDefault("WEATHER_PROVIDERS", "weatherapi,openmeteo")
+	viper.SetDefault("WEATHER_PROVIDER_TIMEOUT", "2s")
+	viper.SetDefault("WEATHERAPI_URL", "")
+	viper.SetDefault("OPENMETEO_URL", "")
+	viper.SetDefault("OPENMETEO_GEOCODING_URL", "")
+	viper.SetDefault("HTTP_CLIENT_TIMEOUT", "10s")
+	viper.SetDefault("HTTP_CLIENT_DIAL_TIMEOUT", "2s")
+	viper.SetDefault("HTTP_CLIENT_TLS_HANDSHAKE_TIMEOUT", "2s")
+	viper.SetDefault("HTTP_CLIENT_IDLE_CONN_TIMEOUT", "90s")
+	viper.SetDefault("HTTP_CLIENT_MAX_IDLE_CONNS_PER_HOST", 16)
+	viper.SetDefault("HTTP_CLIENT_MAX_CONNS_PER_HOST", 64)
+	viper.SetDefault("HTTP_CLIENT_CA_FILE", "")
+	viper.SetDefault("HTTP_CLIENT_INSECURE", "")
+	viper.SetDefault("RETRY_MAX_ATTEMPTS", 3)
+	viper.SetDefault("RETRY_BASE_DELAY", "100ms")
+	viper.SetDefault("RETRY_MAX_DELAY", "2s")
+	viper.SetDefault("RETRY_JITTER", 0.5)
+	viper.SetDefault("RETRY_STATUS_CODES", "429,500,502,503,504")
+	viper.SetDefault("BREAKER_FAILURE_THRESHOLD", 5)
+	viper.SetDefault("BREAKER_OPEN_TIMEOUT", "30s")
+	viper.SetDefault("BREAKER_HALF_OPEN_REQUESTS", 1)
+	viper.SetDefault("UPSTREAM_RATE_LIMITS", "")
+	viper.SetDefault("SERVICE_TOKEN_SECRET", "")
+	viper.SetDefault("SERVICE_TOKEN_ISSUERS", "service-a")
+	viper.SetDefault("ADMIN_API_KEYS", "")
+	viper.SetDefault("WEATHER_CACHE_SIZE", 1000)
+	viper.SetDefault("WEATHER_CACHE_FRESHNESS", "10m")
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("LOG_FORMAT", "text")
+	viper.SetDefault("LOG_CONSOLE", true)
+	viper.SetDefault("BAGGAGE_KEYS", "tenant.id,client.app,request.origin")
+	viper.SetDefault("REQUEST_TIMEOUT", "5s")
+	viper.SetDefault("REQUEST_TIMEOUT_MAX", "30s")
+	viper.SetDefault("GRPC_PORT", 50051)
+	viper.SetDefault("FAULTS_ENABLED", false)
+	viper.SetDefault("FAULTS_RULES", "")
+
 	// 2. Habilita a leitura automática de variáveis de ambiente do SO.
 	viper.AutomaticEnv()
 
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	address "github.com/EnnioSimoes/2-Observabilidade/ServiceB/address"
+	"github.com/EnnioSimoes/2-Observabilidade/ServiceB/configs"
+	weather "github.com/EnnioSimoes/2-Observabilidade/ServiceB/weather"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/breaker"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/deadline"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/temperaturepb"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// temperatureServer serves TemperatureService on top of the same providers as
+// the /temperature/{cep} route, answering with the gRPC codes that match its
+// HTTP statuses.
+type temperatureServer struct {
+	temperaturepb.UnimplementedTemperatureServiceServer
+	addresses address.AddressProvider
+	forecasts weather.WeatherProvider
+}
+
+// newGRPCServer builds the gRPC server. The otelgrpc stats handler starts the
+// server span and extracts the trace context before the interceptors run.
+func newGRPCServer(config *configs.Config, addresses address.AddressProvider, forecasts weather.WeatherProvider) *grpc.Server {
+	interceptors := []grpc.UnaryServerInterceptor{
+		deadline.NewUnaryServerInterceptor(config.RequestTimeout, config.RequestTimeoutMax),
+	}
+	if verifier := newTokenVerifier(config); verifier != nil {
+		interceptors = append(interceptors, verifier.UnaryServerInterceptor())
+	}
+
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(interceptors...),
+	)
+	temperaturepb.RegisterTemperatureServiceServer(srv, &temperatureServer{addresses: addresses, forecasts: forecasts})
+	return srv
+}
+
+func (s *temperatureServer) GetTemperature(ctx context.Context, req *temperaturepb.GetTemperatureRequest) (*temperaturepb.GetTemperatureResponse, error) {
+	// O span do servidor já foi iniciado pelo stats handler do otelgrpc
+	span := trace.SpanFromContext(ctx)
+	cep := req.GetCep()
+	if cep == "" {
+		return nil, status.Error(codes.InvalidArgument, "CEP is required")
+	}
+
+	addr, err := s.addresses.GetCep(cep, ctx)
+	if err != nil {
+		return nil, s.lookupError(ctx, err, codes.InvalidArgument, "invalid zipcode", attribute.String("address.cep", cep))
+	}
+	if addr.Cep == "" {
+		slog.WarnContext(ctx, "Address not found for zipcode", "cep", cep)
+		telemetry.RecordError(span, address.ErrCepNotFound, attribute.String("address.cep", cep))
+		return nil, status.Error(codes.NotFound, "can not find zipcode")
+	}
+
+	reading, err := s.forecasts.GetWeather(addr.City, ctx)
+	if err != nil {
+		return nil, s.lookupError(ctx, err, codes.Internal, "internal server error", attribute.String("address.cep", cep), attribute.String("weather.city", addr.City))
+	}
+
+	t := weather.NewTemperature(reading)
+	resp := &temperaturepb.GetTemperatureResponse{City: t.City, TempC: t.Temp_C, TempF: t.Temp_F, TempK: t.Temp_K, Age: t.Age}
+	if !t.ObservedAt.IsZero() {
+		resp.ObservedAt = timestamppb.New(t.ObservedAt)
+	}
+	return resp, nil
+}
+
+// lookupError records err on the server span and turns it into the status
+// the HTTP route would have answered: DeadlineExceeded for a spent budget,
+// Unavailable with open breakers, and code with message otherwise.
+func (s *temperatureServer) lookupError(ctx context.Context, err error, code codes.Code, message string, attrs ...attribute.KeyValue) error {
+	span := trace.SpanFromContext(ctx)
+	if deadline.Exceeded(ctx) {
+		slog.WarnContext(ctx, "Request deadline exceeded", "error", err)
+		telemetry.RecordError(span, fmt.Errorf("%w: %w", deadline.ErrExceeded, err), attrs...)
+		return status.Error(codes.DeadlineExceeded, "request deadline exceeded")
+	}
+	if openErr, ok := breaker.Refused(err); ok {
+		slog.WarnContext(ctx, "Upstream circuit breaker is open", "upstream", openErr.Name, "error", err)
+		telemetry.RecordError(span, openErr, attrs...)
+		return status.Error(codes.Unavailable, "service unavailable, retry in "+(time.Duration(openErr.RetryAfterSeconds())*time.Second).String())
+	}
+	slog.ErrorContext(ctx, "Error getting temperature", "error", err)
+	telemetry.RecordError(span, err, attrs...)
+	return status.Error(code, message)
+}
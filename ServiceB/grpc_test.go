@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	address "github.com/EnnioSimoes/2-Observabilidade/ServiceB/address"
+	"github.com/EnnioSimoes/2-Observabilidade/ServiceB/configs"
+	weather "github.com/EnnioSimoes/2-Observabilidade/ServiceB/weather"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/auth"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/temperaturepb"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestGRPCClient serves TemperatureService in memory with a ViaCEP
+// stand-in that knows only 01001000 and an Open-Meteo stand-in that answers
+// 0°C, recording every span in the returned exporter.
+func newTestGRPCClient(t *testing.T, config *configs.Config, opts ...grpc.DialOption) (temperaturepb.TemperatureServiceClient, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	viacep := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ws/01001000/json/" {
+			w.Write([]byte(`{"erro":"true"}`))
+			return
+		}
+		w.Write([]byte(`{"cep":"01001-000","logradouro":"Praça da Sé","bairro":"Sé","localidade":"São Paulo","uf":"SP"}`))
+	}))
+	t.Cleanup(viacep.Close)
+	openMeteo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/search":
+			w.Write([]byte(`{"results":[{"name":"São Paulo","latitude":-23.5475,"longitude":-46.63611}]}`))
+		default:
+			w.Write([]byte(`{"current":{"time":"2024-05-01T12:00","temperature_2m":0}}`))
+		}
+	}))
+	t.Cleanup(openMeteo.Close)
+
+	addresses := address.NewFallbackProvider(0, address.NewViaCepProvider(viacep.URL, nil))
+	forecasts := weather.NewFallbackProvider(0, weather.NewOpenMeteoProvider(openMeteo.URL, openMeteo.URL, nil))
+
+	lis := bufconn.Listen(1 << 20)
+	srv := newGRPCServer(config, addresses, forecasts)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	opts = append(opts,
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return temperaturepb.NewTemperatureServiceClient(conn), exporter
+}
+
+func TestGRPCServerAnswersTemperature(t *testing.T) {
+	client, exporter := newTestGRPCClient(t, &configs.Config{RequestTimeout: 5 * time.Second})
+
+	resp, err := client.GetTemperature(context.Background(), &temperaturepb.GetTemperatureRequest{Cep: "01001000"})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if resp.GetCity() != "São Paulo" || resp.GetTempC() != 0 || resp.GetTempK() != 273 {
+		t.Errorf("Expected 0°C in São Paulo, but got %+v", resp)
+	}
+	if resp.GetObservedAt().AsTime().IsZero() {
+		t.Errorf("Expected observed_at to be set")
+	}
+
+	server := findSpan(t, exporter.GetSpans(), temperaturepb.TemperatureService_GetTemperature_FullMethodName[1:], trace.SpanID{})
+	if server.SpanKind != trace.SpanKindServer {
+		t.Errorf("Expected a server span, but got %s", server.SpanKind)
+	}
+	if ms := attributeOf(server, "request.timeout_ms").AsInt64(); ms <= 0 || ms > 5000 {
+		t.Errorf("Expected request.timeout_ms within the 5s budget, but got %d", ms)
+	}
+}
+
+func TestGRPCServerAnswersNotFound(t *testing.T) {
+	client, exporter := newTestGRPCClient(t, &configs.Config{RequestTimeout: 5 * time.Second})
+
+	_, err := client.GetTemperature(context.Background(), &temperaturepb.GetTemperatureRequest{Cep: "99999999"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected NotFound, but got %v", err)
+	}
+
+	server := findSpan(t, exporter.GetSpans(), temperaturepb.TemperatureService_GetTemperature_FullMethodName[1:], trace.SpanID{})
+	assertError(t, server, "not_found")
+}
+
+func TestGRPCServerRequiresServiceToken(t *testing.T) {
+	config := &configs.Config{RequestTimeout: 5 * time.Second, ServiceTokenSecrets: []string{"secret"}, ServiceTokenIssuers: []string{"service-a"}}
+
+	client, _ := newTestGRPCClient(t, config)
+	_, err := client.GetTemperature(context.Background(), &temperaturepb.GetTemperatureRequest{Cep: "01001000"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without a token, but got %v", err)
+	}
+
+	signer := auth.NewSigner("service-a", "service-b", []byte("secret"), time.Minute)
+	client, _ = newTestGRPCClient(t, config, grpc.WithPerRPCCredentials(signer))
+	if _, err := client.GetTemperature(context.Background(), &temperaturepb.GetTemperatureRequest{Cep: "01001000"}); err != nil {
+		t.Errorf("Expected no error with a token, but got %v", err)
+	}
+}
@@ -4,153 +4,473 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"encoding/json"
 
 	address "github.com/EnnioSimoes/2-Observabilidade/ServiceB/address"
+	"github.com/EnnioSimoes/2-Observabilidade/ServiceB/configs"
 	weather "github.com/EnnioSimoes/2-Observabilidade/ServiceB/weather"
-	"github.com/go-chi/chi/middleware"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/auth"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/breaker"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/deadline"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/faults"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/httpclient"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/ratelimit"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/retry"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
 	"github.com/go-chi/chi/v5"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var serviceName = semconv.ServiceNameKey.String("service-b")
+// newHandler builds the /temperature/{cep} handler on top of the given
+// address and weather providers.
+func newHandler(addresses address.AddressProvider, forecasts weather.WeatherProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// O span do servidor já foi iniciado pelo middleware de telemetria
+		ctx := r.Context()
+		span := trace.SpanFromContext(ctx)
 
-// Initialize a gRPC connection to be used by both the tracer and meter
-// providers.
-func initConn() (*grpc.ClientConn, error) {
-	// It connects the OpenTelemetry Collector through local gRPC connection.
-	// You may replace `localhost:4317` with your endpoint.
-	conn, err := grpc.NewClient("otel-collector:4317",
-		// Note the use of insecure transport here. TLS is recommended in production.
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create gRPC connection to collector: %w", err)
+		cep := chi.URLParam(r, "cep")
+		if cep == "" {
+			writeError(w, r, http.StatusBadRequest, "CEP is required")
+			return
+		}
+
+		addr, err := addresses.GetCep(cep, ctx)
+		if err != nil && deadline.Exceeded(ctx) {
+			writeDeadlineExceeded(w, r, err, attribute.String("address.cep", cep))
+			return
+		}
+		if openErr, ok := breaker.Refused(err); ok {
+			writeUnavailable(w, r, openErr, err, attribute.String("address.cep", cep))
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Error getting address", "cep", cep, "error", err)
+			telemetry.RecordError(span, err, attribute.String("address.cep", cep))
+			writeError(w, r, http.StatusUnprocessableEntity, "invalid zipcode")
+			return
+		}
+
+		if addr.Cep == "" {
+			slog.WarnContext(ctx, "Address not found for zipcode", "cep", cep)
+			telemetry.RecordError(span, address.ErrCepNotFound, attribute.String("address.cep", cep))
+			writeError(w, r, http.StatusNotFound, "can not find zipcode")
+			return
+		}
+
+		reading, err := forecasts.GetWeather(addr.City, ctx)
+		if err != nil && deadline.Exceeded(ctx) {
+			writeDeadlineExceeded(w, r, err, attribute.String("address.cep", cep), attribute.String("weather.city", addr.City))
+			return
+		}
+		if openErr, ok := breaker.Refused(err); ok {
+			writeUnavailable(w, r, openErr, err, attribute.String("address.cep", cep), attribute.String("weather.city", addr.City))
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Error getting temperature", "city", addr.City, "error", err)
+			telemetry.RecordError(span, err, attribute.String("address.cep", cep), attribute.String("weather.city", addr.City))
+			writeError(w, r, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(weather.NewTemperature(reading))
 	}
+}
+
+// errorResponse is the JSON body of every error answer. TraceID lets support
+// find the request in Zipkin.
+type errorResponse struct {
+	Error   string `json:"error"`
+	TraceID string `json:"trace_id,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: message, TraceID: telemetry.TraceID(r.Context())})
+}
 
-	return conn, err
+// writeDeadlineExceeded answers 504 when the request ran out of its deadline
+// budget while waiting for err.
+func writeDeadlineExceeded(w http.ResponseWriter, r *http.Request, err error, attrs ...attribute.KeyValue) {
+	ctx := r.Context()
+	slog.WarnContext(ctx, "Request deadline exceeded", "error", err)
+	telemetry.RecordError(trace.SpanFromContext(ctx), fmt.Errorf("%w: %w", deadline.ErrExceeded, err), attrs...)
+	writeError(w, r, http.StatusGatewayTimeout, "request deadline exceeded")
 }
 
-// Initializes an OTLP exporter, and configures the corresponding trace provider.
-func initTracerProvider(ctx context.Context, res *resource.Resource, conn *grpc.ClientConn) (func(context.Context) error, error) {
-	// Set up a trace exporter
-	traceExporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
+// writeUnavailable answers a fast 503 when err failed because the breakers of
+// the upstreams are open, telling the client when to come back.
+func writeUnavailable(w http.ResponseWriter, r *http.Request, openErr *breaker.OpenError, err error, attrs ...attribute.KeyValue) {
+	ctx := r.Context()
+	slog.WarnContext(ctx, "Upstream circuit breaker is open", "upstream", openErr.Name, "error", err)
+	telemetry.RecordError(trace.SpanFromContext(ctx), openErr, attrs...)
+	w.Header().Set("Retry-After", strconv.Itoa(openErr.RetryAfterSeconds()))
+	writeError(w, r, http.StatusServiceUnavailable, "service unavailable")
+}
+
+// newPurgeAddressCacheHandler drops a single CEP from the address cache, or
+// every cached CEP when the route has no {cep}.
+func newPurgeAddressCacheHandler(addresses *address.CachedProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		purged := 0
+		if cep := chi.URLParam(r, "cep"); cep != "" {
+			if addresses.Forget(cep) {
+				purged = 1
+			}
+		} else {
+			purged = addresses.Purge()
+		}
+		slog.InfoContext(r.Context(), "Address cache entries purged", "purged", purged)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]int{"purged": purged})
+	}
+}
+
+// newAdminRouter serves the admin routes to the holders of the keys in
+// ADMIN_API_KEYS, sent in the X-API-Key header. They sit outside the service
+// token check, so operators don't need a token of Service A. /faults only
+// exists when fault injection is enabled.
+func newAdminRouter(keys *auth.KeyStore, addresses *address.CachedProvider, injector *faults.Injector) http.Handler {
+	r := chi.NewRouter()
+	r.Use(keys.Middleware)
+	r.Delete("/cache/address", newPurgeAddressCacheHandler(addresses))
+	r.Delete("/cache/address/{cep}", newPurgeAddressCacheHandler(addresses))
+	if injector != nil {
+		r.Handle("/faults", injector.Handler())
+	}
+	return r
+}
+
+// newUpstreamClient builds the client of a single upstream, with its own
+// connection pool. Injected faults sit below the instrumented transport so
+// they show up on its client span.
+func newUpstreamClient(config *configs.Config, injector *faults.Injector, name string) (*http.Client, error) {
+	opts := httpclient.Options{
+		Timeout:             config.HTTPClientTimeout,
+		DialTimeout:         config.HTTPClientDialTimeout,
+		TLSHandshakeTimeout: config.HTTPClientTLSHandshakeTimeout,
+		IdleConnTimeout:     config.HTTPClientIdleConnTimeout,
+		MaxIdleConnsPerHost: config.HTTPClientMaxIdleConnsPerHost,
+		MaxConnsPerHost:     config.HTTPClientMaxConnsPerHost,
+		CAFile:              config.HTTPClientCAFile,
+		Insecure:            slices.Contains(config.HTTPClientInsecure, name),
+	}
+	if opts.Insecure {
+		slog.Warn("TLS certificate verification is disabled", "upstream", name)
+	}
+
+	rateLimit, err := newRateLimitWrapper(config, name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+		return nil, err
 	}
 
-	// Register the trace exporter with a TracerProvider, using a batch
-	// span processor to aggregate spans before export.
-	bsp := sdktrace.NewBatchSpanProcessor(traceExporter)
-	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-		sdktrace.WithResource(res),
-		sdktrace.WithSpanProcessor(bsp),
-	)
-	otel.SetTracerProvider(tracerProvider)
+	var wrappers []httpclient.Wrapper
+	if injector != nil {
+		wrappers = append(wrappers, injector.Transport)
+	}
+	wrappers = append(wrappers, func(next http.RoundTripper) http.RoundTripper {
+		return telemetry.NewTransport("service-b", next)
+	}, rateLimit, newRetryWrapper(config, name))
 
-	// Set global propagator to tracecontext (the default is no-op).
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+	client, err := httpclient.New(opts, wrappers...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return client, nil
+}
 
-	// Shutdown will flush any remaining spans and shut down the exporter.
-	return tracerProvider.Shutdown, nil
+// newRetryWrapper resends failed calls to the upstream name around the
+// instrumented transport, so every attempt gets its own client span. The
+// quota statuses of WeatherAPI are not retried: the provider moves on to the
+// next key instead of hammering the exhausted one.
+func newRetryWrapper(config *configs.Config, name string) httpclient.Wrapper {
+	statusCodes := config.RetryStatusCodes
+	if name == weather.WeatherApiName {
+		statusCodes = slices.DeleteFunc(slices.Clone(statusCodes), func(code int) bool {
+			return slices.Contains(weather.QuotaStatusCodes, code)
+		})
+	}
+	policy := retry.Policy{
+		MaxAttempts: config.RetryMaxAttempts,
+		BaseDelay:   config.RetryBaseDelay,
+		MaxDelay:    config.RetryMaxDelay,
+		Jitter:      config.RetryJitter,
+		StatusCodes: statusCodes,
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return retry.NewTransport(policy, next)
+	}
 }
 
-func handler(w http.ResponseWriter, r *http.Request) {
-	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+// newRateLimitWrapper paces the calls to the upstream name with the rate
+// UPSTREAM_RATE_LIMITS gives it, and tracks the quota the upstream reports.
+// It sits inside the retries so every attempt takes a token.
+func newRateLimitWrapper(config *configs.Config, name string) (httpclient.Wrapper, error) {
+	var bucket *ratelimit.Bucket
+	for _, entry := range config.UpstreamRateLimits {
+		upstream, value, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		if strings.TrimSpace(upstream) != name {
+			continue
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid UPSTREAM_RATE_LIMITS entry: %q", entry)
+		}
+		bucket = ratelimit.NewBucket(rate, int(math.Ceil(rate)))
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return ratelimit.NewTransport("service-b", name, bucket, next)
+	}, nil
+}
 
-	// Intrumenta o span para a chamada interna
-	// Pega o tracer novamente (ou poderia ser passado como argumento)
-	tracer := otel.Tracer("service-b")
+// newBreaker builds the circuit breaker of one upstream.
+func newBreaker(config *configs.Config, name string) *breaker.Breaker {
+	return breaker.New("service-b", name, breaker.Settings{
+		FailureThreshold: config.BreakerFailureThreshold,
+		OpenTimeout:      config.BreakerOpenTimeout,
+		HalfOpenRequests: config.BreakerHalfOpenRequests,
+	})
+}
 
-	// Inicia um span filho, pois estamos usando o contexto do `helloHandlerSpan`
-	_, span := tracer.Start(ctx, "startGetTemperatureSpan")
-	defer span.End()
+// newAddressProvider chains the configured CEP providers in priority order.
+func newAddressProvider(config *configs.Config, newClient func(name string) (*http.Client, error)) (address.AddressProvider, error) {
+	baseURLs := map[string]string{
+		address.ViaCepName:    config.ViaCepURL,
+		address.BrasilApiName: config.BrasilApiURL,
+		address.OpenCepName:   config.OpenCepURL,
+	}
 
-	cep := chi.URLParam(r, "cep")
-	if cep == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		return
+	var providers []address.AddressProvider
+	for _, name := range config.AddressProviders {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		client, err := newClient(name)
+		if err != nil {
+			return nil, err
+		}
+		p, err := address.NewProvider(name, baseURLs[name], client)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, address.NewBreakerProvider(p, newBreaker(config, name)))
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no address provider configured")
 	}
 
-	addr, err := address.GetCep(cep, ctx)
-	if err != nil {
-		log.Println("Error getting address:", err)
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid zipcode"})
-		return
+	return address.NewFallbackProvider(config.AddressProviderTimeout, providers...), nil
+}
+
+// newWeatherProvider chains the configured weather providers in priority
+// order. WeatherAPI is left out when no API key is configured.
+func newWeatherProvider(config *configs.Config, newClient func(name string) (*http.Client, error)) (weather.WeatherProvider, error) {
+	opts := weather.Options{
+		WeatherApiURL:         config.WeatherApiURL,
+		WeatherApiKeys:        weather.NewKeyPool(config.WeatherapiKeys, config.WeatherapiKeyCooldown),
+		OpenMeteoURL:          config.OpenMeteoURL,
+		OpenMeteoGeocodingURL: config.OpenMeteoGeocodingURL,
 	}
 
-	if addr.Cep == "" {
-		log.Println("Error: Address not found for zipcode:", cep)
-		w.WriteHeader(http.StatusNotFound)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"error": "can not find zipcode"})
-		return
+	var providers []weather.WeatherProvider
+	for _, name := range config.WeatherProviders {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name == weather.WeatherApiName && opts.WeatherApiKeys.Len() == 0 {
+			slog.Warn("WEATHER_API_KEY is not set, skipping weather provider", "provider", name)
+			continue
+		}
+		client, err := newClient(name)
+		if err != nil {
+			return nil, err
+		}
+		opts.Client = client
+		p, err := weather.NewProvider(name, opts)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, weather.NewBreakerProvider(p, newBreaker(config, name)))
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no weather provider configured")
+	}
+
+	return weather.NewFallbackProvider(config.WeatherProviderTimeout, providers...), nil
+}
+
+// newTokenVerifier returns nil when no SERVICE_TOKEN_SECRET is set. Otherwise
+// only the callers in SERVICE_TOKEN_ISSUERS holding a token signed with one of
+// the secrets are let in.
+func newTokenVerifier(config *configs.Config) *auth.Verifier {
+	var secrets [][]byte
+	for _, secret := range config.ServiceTokenSecrets {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			secrets = append(secrets, []byte(secret))
+		}
+	}
+	if len(secrets) == 0 {
+		return nil
 	}
+	var issuers []string
+	for _, issuer := range config.ServiceTokenIssuers {
+		issuers = append(issuers, strings.TrimSpace(issuer))
+	}
+	return auth.NewVerifier("service-b", issuers, secrets...)
+}
 
-	temperature, err := weather.GetWeather(addr.Localidade, ctx)
+// newFaultInjector returns nil unless FAULTS_ENABLED is set, so faults can
+// never be injected by accident. FAULTS_RULES holds the initial rules.
+func newFaultInjector(config *configs.Config) (*faults.Injector, error) {
+	if !config.FaultsEnabled {
+		return nil, nil
+	}
+	injector := faults.New()
+	if config.FaultsRules == "" {
+		return injector, nil
+	}
+	rules, err := faults.ParseRules([]byte(config.FaultsRules))
 	if err != nil {
-		log.Println("Error getting temperature:", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"error": "internal server error"})
-		return
+		return nil, err
+	}
+	if err := injector.SetRules(rules); err != nil {
+		return nil, fmt.Errorf("invalid FAULTS_RULES: %w", err)
 	}
+	return injector, nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(temperature)
+// routePattern returns the route template chi matched for r, e.g.
+// /temperature/{cep}. It is only known once chi has routed the request.
+func routePattern(r *http.Request) string {
+	return chi.RouteContext(r.Context()).RoutePattern()
 }
 
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	conn, err := initConn()
+	config, err := configs.LoadConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			// The service name used to display traces in backends
-			serviceName,
-		),
+	shutdownTelemetry, err := telemetry.Setup(ctx,
+		telemetry.WithServiceName("service-b"),
+		telemetry.WithLogLevel(config.LogLevel),
+		telemetry.WithConsoleLog(config.LogConsole, config.LogFormat),
+		telemetry.WithBaggageKeys(config.BaggageKeys...),
 	)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer func() {
+		// The signal context is already canceled here, so flushing needs its own.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTelemetry(ctx); err != nil {
+			log.Printf("failed to shutdown telemetry: %s", err)
+		}
+	}()
+
+	injector, err := newFaultInjector(config)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	shutdownTracerProvider, err := initTracerProvider(ctx, res, conn)
+	// Cada provedor recebe o seu próprio cliente instrumentado, criado uma
+	// única vez aqui
+	newClient := func(name string) (*http.Client, error) {
+		return newUpstreamClient(config, injector, name)
+	}
+
+	providers, err := newAddressProvider(config, newClient)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer func() {
-		if err := shutdownTracerProvider(ctx); err != nil {
-			log.Fatalf("failed to shutdown TracerProvider: %s", err)
-		}
-	}()
+	addresses := address.NewCachedProvider(address.NewCoalescingProvider(providers), config.AddressCacheSize, config.AddressCacheTTL, config.AddressCacheNegativeTTL)
+
+	weatherProviders, err := newWeatherProvider(config, newClient)
+	if err != nil {
+		log.Fatal(err)
+	}
+	forecasts := weather.NewCachedProvider(weather.NewCoalescingProvider(weatherProviders), config.WeatherCacheSize, config.WeatherCacheFreshness)
 
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Get("/temperature/{cep}", handler)
+	r.Use(telemetry.NewServerMiddleware("service-b", routePattern))
+	r.Use(deadline.NewMiddleware(config.RequestTimeout, config.RequestTimeoutMax))
+	r.Use(telemetry.RequestLogger)
+	r.Use(telemetry.NewMetricsMiddleware("service-b", routePattern, config.BaggageKeys))
+	if injector != nil {
+		slog.Warn("Fault injection is enabled", "rules", len(injector.Rules()))
+		r.Use(injector.Middleware)
+	}
+	r.Group(func(r chi.Router) {
+		if verifier := newTokenVerifier(config); verifier != nil {
+			r.Use(verifier.Middleware)
+		} else {
+			slog.Warn("SERVICE_TOKEN_SECRET is not set, calls are not authenticated")
+		}
+		r.Get("/temperature/{cep}", newHandler(addresses, forecasts))
+	})
 
-	log.Println("Starting server on :8081")
-	err = http.ListenAndServe(":8081", r)
+	// As rotas de administração só existem com ADMIN_API_KEYS definido
+	adminKeys, err := auth.LoadKeys(config.AdminAPIKeys, "")
 	if err != nil {
+		log.Fatal(err)
+	}
+	if adminKeys.Len() > 0 {
+		r.Mount("/admin", newAdminRouter(adminKeys, addresses, injector))
+	} else {
+		slog.Info("ADMIN_API_KEYS is not set, admin routes are disabled")
+	}
+
+	srv := &http.Server{Addr: ":8081", Handler: r}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	if config.GRPCPort > 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", config.GRPCPort))
+		if err != nil {
+			log.Fatal(err)
+		}
+		grpcSrv := newGRPCServer(config, addresses, forecasts)
+		go func() {
+			<-ctx.Done()
+			grpcSrv.GracefulStop()
+		}()
+		go func() {
+			slog.Info("Starting gRPC server", "addr", lis.Addr().String())
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Fatalf("An error occurred while starting the gRPC server: %v", err)
+			}
+		}()
+	}
+
+	slog.Info("Starting server", "addr", srv.Addr)
+	err = srv.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
 		log.Fatalf("An error occurred while starting the server: %v", err)
 	}
 }
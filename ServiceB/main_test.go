@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	address "github.com/EnnioSimoes/2-Observabilidade/ServiceB/address"
+	"github.com/EnnioSimoes/2-Observabilidade/ServiceB/configs"
+	weather "github.com/EnnioSimoes/2-Observabilidade/ServiceB/weather"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/auth"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/breaker"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/deadline"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestServer serves /temperature/{cep} with a ViaCEP stand-in that knows
+// 01001000 and an Open-Meteo stand-in that always fails, recording every span
+// in the returned exporter.
+func newTestServer(t *testing.T) (*httptest.Server, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	viacep := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"cep":"01001-000","logradouro":"Praça da Sé","bairro":"Sé","localidade":"São Paulo","uf":"SP"}`))
+	}))
+	t.Cleanup(viacep.Close)
+	openMeteo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(openMeteo.Close)
+
+	client := &http.Client{Transport: telemetry.NewTransport("service-b", nil)}
+	addresses := address.NewFallbackProvider(0, address.NewViaCepProvider(viacep.URL, client))
+	forecasts := weather.NewFallbackProvider(0, weather.NewOpenMeteoProvider(openMeteo.URL, openMeteo.URL, client))
+
+	r := chi.NewRouter()
+	r.Use(telemetry.NewServerMiddleware("service-b", routePattern))
+	r.Get("/temperature/{cep}", newHandler(addresses, forecasts))
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv, exporter
+}
+
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string, parent trace.SpanID) tracetest.SpanStub {
+	t.Helper()
+	for _, s := range spans {
+		if s.Name == name && s.Parent.SpanID() == parent {
+			return s
+		}
+	}
+	t.Fatalf("Expected a span %s with parent %s, but got %v", name, parent, spans.Snapshots())
+	return tracetest.SpanStub{}
+}
+
+func attributeOf(s tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func assertError(t *testing.T, s tracetest.SpanStub, category string) {
+	t.Helper()
+	if s.Status.Code != codes.Error {
+		t.Errorf("Expected span %s to have status Error, but got %s", s.Name, s.Status.Code)
+	}
+	if len(s.Events) == 0 || s.Events[0].Name != "exception" {
+		t.Errorf("Expected span %s to record an exception event", s.Name)
+	}
+	if got := attributeOf(s, "error.category").AsString(); got != category {
+		t.Errorf("Expected span %s to have error.category %s, but got %s", s.Name, category, got)
+	}
+}
+
+func TestHandlerRecordsWeatherFailure(t *testing.T) {
+	srv, exporter := newTestServer(t)
+
+	resp, err := http.Get(srv.URL + "/temperature/01001000")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, but got %d", resp.StatusCode)
+	}
+
+	spans := exporter.GetSpans()
+
+	server := findSpan(t, spans, "GET /temperature/{cep}", trace.SpanID{})
+	assertError(t, server, "upstream_unavailable")
+	if city := attributeOf(server, "weather.city").AsString(); city != "São Paulo" {
+		t.Errorf("Expected weather.city São Paulo, but got %s", city)
+	}
+	if cep := attributeOf(server, "address.cep").AsString(); cep != "01001000" {
+		t.Errorf("Expected address.cep 01001000, but got %s", cep)
+	}
+
+	location := findSpan(t, spans, "GetLocationByCepSpan", server.SpanContext.SpanID())
+	if location.Status.Code == codes.Error {
+		t.Errorf("Expected the address lookup to succeed, but it failed")
+	}
+
+	lookup := findSpan(t, spans, "GetWeatherSpan", server.SpanContext.SpanID())
+	assertError(t, lookup, "upstream_unavailable")
+
+	attempt := findSpan(t, spans, "GetWeather openmeteo", lookup.SpanContext.SpanID())
+	assertError(t, attempt, "upstream_status")
+	if status := attributeOf(attempt, "upstream.status_code").AsInt64(); status != http.StatusBadGateway {
+		t.Errorf("Expected upstream.status_code 502, but got %d", status)
+	}
+
+	client := findSpan(t, spans, "GET", attempt.SpanContext.SpanID())
+	if client.Status.Code != codes.Error {
+		t.Errorf("Expected the client span to have status Error, but got %s", client.Status.Code)
+	}
+}
+
+func TestHandlerRecordsInvalidCep(t *testing.T) {
+	srv, exporter := newTestServer(t)
+
+	resp, err := http.Get(srv.URL + "/temperature/123")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, but got %d", resp.StatusCode)
+	}
+
+	spans := exporter.GetSpans()
+
+	server := findSpan(t, spans, "GET /temperature/{cep}", trace.SpanID{})
+	assertError(t, server, "invalid_cep")
+
+	var body errorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Expected a JSON error body, but got %v", err)
+	}
+	traceID := server.SpanContext.TraceID().String()
+	if body.Error != "invalid zipcode" || body.TraceID != traceID {
+		t.Errorf("Expected error invalid zipcode with trace_id %s, but got %+v", traceID, body)
+	}
+	if got := resp.Header.Get("X-Trace-Id"); got != traceID {
+		t.Errorf("Expected X-Trace-Id %s, but got %s", traceID, got)
+	}
+
+	location := findSpan(t, spans, "GetLocationByCepSpan", server.SpanContext.SpanID())
+	assertError(t, location, "invalid_cep")
+	if cep := attributeOf(location, "address.cep").AsString(); cep != "123" {
+		t.Errorf("Expected address.cep 123, but got %s", cep)
+	}
+}
+
+// hangingAddresses never answers before the request gives up.
+type hangingAddresses struct{}
+
+func (hangingAddresses) Name() string {
+	return "hanging"
+}
+
+func (hangingAddresses) GetCep(cep string, ctx context.Context) (*address.Address, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestHandlerAnswers504WhenDeadlineIsSpent(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	r := chi.NewRouter()
+	r.Use(telemetry.NewServerMiddleware("service-b", routePattern))
+	r.Use(deadline.NewMiddleware(5*time.Second, 0))
+	r.Get("/temperature/{cep}", newHandler(address.NewFallbackProvider(0, hangingAddresses{}, hangingAddresses{}), nil))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/temperature/01001000", nil)
+	req.Header.Set(deadline.Header, "50")
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("Expected status 504, but got %d", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the caller's 50ms budget to be honored, but took %s", elapsed)
+	}
+
+	spans := exporter.GetSpans()
+	server := findSpan(t, spans, "GET /temperature/{cep}", trace.SpanID{})
+	assertError(t, server, "deadline_exceeded")
+	if budget := attributeOf(server, "request.timeout_ms").AsInt64(); budget != 50 {
+		t.Errorf("Expected request.timeout_ms 50, but got %d", budget)
+	}
+
+	location := findSpan(t, spans, "GetLocationByCepSpan", server.SpanContext.SpanID())
+	attempts := 0
+	for _, s := range spans {
+		if s.Parent.SpanID() == location.SpanContext.SpanID() {
+			attempts++
+		}
+	}
+	if attempts != 1 {
+		t.Errorf("Expected the spent deadline to skip the second provider, but got %d attempts", attempts)
+	}
+}
+
+func TestHandlerAnswers503WhenBreakersAreOpen(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	b := breaker.New("test", address.ViaCepName, breaker.Settings{FailureThreshold: 1, OpenTimeout: time.Minute})
+	addresses := address.NewFallbackProvider(0, address.NewBreakerProvider(address.NewViaCepProvider(failing.URL, nil), b))
+
+	r := chi.NewRouter()
+	r.Use(telemetry.NewServerMiddleware("service-b", routePattern))
+	r.Get("/temperature/{cep}", newHandler(addresses, nil))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	// A primeira falha abre o breaker
+	resp, err := http.Get(srv.URL + "/temperature/01001000")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+
+	exporter.Reset()
+	resp, err = http.Get(srv.URL + "/temperature/01001000")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, but got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "60" {
+		t.Errorf("Expected Retry-After 60, but got %q", got)
+	}
+
+	spans := exporter.GetSpans()
+	server := findSpan(t, spans, "GET /temperature/{cep}", trace.SpanID{})
+	assertError(t, server, "circuit_open")
+
+	location := findSpan(t, spans, "GetLocationByCepSpan", server.SpanContext.SpanID())
+	attempt := findSpan(t, spans, "GetLocationByCep viacep", location.SpanContext.SpanID())
+	if state := attributeOf(attempt, "circuit_breaker.state").AsString(); state != "open" {
+		t.Errorf("Expected circuit_breaker.state open, but got %q", state)
+	}
+}
+
+func TestHandlerIgnoresOpenBreakersWhenOtherProvidersFail(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	b := breaker.New("test", address.ViaCepName, breaker.Settings{FailureThreshold: 1, OpenTimeout: time.Minute})
+	addresses := address.NewFallbackProvider(0,
+		address.NewBreakerProvider(address.NewViaCepProvider(failing.URL, nil), b),
+		address.NewViaCepProvider(failing.URL, nil),
+	)
+
+	r := chi.NewRouter()
+	r.Get("/temperature/{cep}", newHandler(addresses, nil))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for i := range 2 {
+		resp, err := http.Get(srv.URL + "/temperature/01001000")
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status 422 on request %d, but got %d", i+1, resp.StatusCode)
+		}
+		if got := resp.Header.Get("Retry-After"); got != "" {
+			t.Errorf("Expected no Retry-After on request %d, but got %q", i+1, got)
+		}
+	}
+	if b.State() != breaker.Open {
+		t.Errorf("Expected the breaker of the first provider to be open, but got %s", b.State())
+	}
+}
+
+func TestTokenVerifierOnlyLetsTrustedCallersIn(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	if newTokenVerifier(&configs.Config{ServiceTokenSecrets: []string{" "}}) != nil {
+		t.Errorf("Expected no verifier without a secret")
+	}
+	verifier := newTokenVerifier(&configs.Config{
+		ServiceTokenSecrets: []string{"old", "secret"},
+		ServiceTokenIssuers: []string{" service-a"},
+	})
+	r := chi.NewRouter()
+	r.Use(telemetry.NewServerMiddleware("service-b", routePattern))
+	r.Use(verifier.Middleware)
+	r.Get("/temperature/{cep}", func(w http.ResponseWriter, r *http.Request) {})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	get := func(client *http.Client) int {
+		req, _ := http.NewRequestWithContext(auth.WithPrincipal(context.Background(), "mobile"), http.MethodGet, srv.URL+"/temperature/01001000", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := get(http.DefaultClient); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, but got %d", status)
+	}
+	signer := auth.NewSigner("service-a", "service-b", []byte("secret"), time.Minute)
+	if status := get(&http.Client{Transport: signer.Transport(nil)}); status != http.StatusOK {
+		t.Fatalf("Expected status 200 with a token, but got %d", status)
+	}
+
+	spans := exporter.GetSpans()
+	assertError(t, spans[0], "unauthenticated")
+	if got := attributeOf(spans[1], "auth.principal").AsString(); got != "service-a" {
+		t.Errorf("Expected auth.principal service-a, but got %q", got)
+	}
+	if got := attributeOf(spans[1], "enduser.id").AsString(); got != "mobile" {
+		t.Errorf("Expected enduser.id mobile, but got %q", got)
+	}
+}
+
+func TestAdminRouterRequiresAdminKey(t *testing.T) {
+	keys, err := auth.LoadKeys([]string{"ops=admin-key"}, "")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	r := chi.NewRouter()
+	r.Mount("/admin", newAdminRouter(keys, address.NewCachedProvider(hangingAddresses{}, 10, time.Hour, time.Minute), nil))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	purge := func(key string) int {
+		req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/admin/cache/address", nil)
+		if key != "" {
+			req.Header.Set(auth.APIKeyHeader, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := purge(""); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a key, but got %d", status)
+	}
+	if status := purge("service-token"); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with an unknown key, but got %d", status)
+	}
+	if status := purge("admin-key"); status != http.StatusOK {
+		t.Errorf("Expected status 200 with the admin key, but got %d", status)
+	}
+}
+
+func TestRetryWrapperLeavesWeatherApiQuotaToKeyRotation(t *testing.T) {
+	config := &configs.Config{
+		RetryMaxAttempts: 3,
+		RetryBaseDelay:   time.Millisecond,
+		RetryMaxDelay:    time.Millisecond,
+		RetryStatusCodes: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	}
+
+	attempts := func(name string, status int) int {
+		calls := 0
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(status)
+		}))
+		defer upstream.Close()
+
+		client := &http.Client{Transport: newRetryWrapper(config, name)(http.DefaultTransport)}
+		resp, err := client.Get(upstream.URL)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		resp.Body.Close()
+		return calls
+	}
+
+	if n := attempts(weather.WeatherApiName, http.StatusTooManyRequests); n != 1 {
+		t.Errorf("Expected a single attempt on a WeatherAPI 429, but got %d", n)
+	}
+	if n := attempts(weather.WeatherApiName, http.StatusServiceUnavailable); n != 3 {
+		t.Errorf("Expected 3 attempts on a WeatherAPI 503, but got %d", n)
+	}
+	if n := attempts(weather.OpenMeteoName, http.StatusTooManyRequests); n != 3 {
+		t.Errorf("Expected 3 attempts on an Open-Meteo 429, but got %d", n)
+	}
+	if len(config.RetryStatusCodes) != 2 {
+		t.Errorf("Expected the configured status codes to be left alone, but got %v", config.RetryStatusCodes)
+	}
+}
@@ -0,0 +1,45 @@
+package weather
+
+import (
+	"context"
+	"errors"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/breaker"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/ratelimit"
+)
+
+// BreakerProvider stops calling a provider that keeps failing. While its
+// breaker is open, lookups fail at once with a *breaker.OpenError, so the
+// fallback chain moves on to the next provider without waiting.
+type BreakerProvider struct {
+	next    WeatherProvider
+	breaker *breaker.Breaker
+}
+
+func NewBreakerProvider(next WeatherProvider, b *breaker.Breaker) *BreakerProvider {
+	return &BreakerProvider{next: next, breaker: b}
+}
+
+func (p *BreakerProvider) Name() string {
+	return p.next.Name()
+}
+
+func (p *BreakerProvider) GetWeather(city string, ctx context.Context) (*Reading, error) {
+	done, err := p.breaker.Allow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reading, err := p.next.GetWeather(city, ctx)
+	done(upstreamFailed(err))
+	return reading, err
+}
+
+// upstreamFailed tells upstream failures apart from answers about the city
+// itself, from calls held back by our own rate limiter and from callers
+// giving up, which say nothing about the provider's health.
+func upstreamFailed(err error) bool {
+	return err != nil &&
+		!errors.Is(err, ErrCityNotFound) &&
+		!errors.Is(err, ratelimit.ErrLimited) &&
+		!errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,29 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/breaker"
+)
+
+func TestBreakerProviderFailsFastWhenOpen(t *testing.T) {
+	b := breaker.New("test", WeatherApiName, breaker.Settings{FailureThreshold: 1, OpenTimeout: time.Minute})
+	p := NewBreakerProvider(NewWeatherApiProvider(newWeatherApiStandIn(t, http.StatusServiceUnavailable).URL, newTestKeyPool(t, []string{"key"}, time.Minute), nil), b)
+
+	if _, err := p.GetWeather("São Paulo", context.Background()); err == nil {
+		t.Fatalf("Expected the failing provider to return an error, but got none")
+	}
+
+	_, err := p.GetWeather("São Paulo", context.Background())
+	var openErr *breaker.OpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("Expected a *breaker.OpenError, but got %v", err)
+	}
+	if openErr.RetryAfterSeconds() != 60 {
+		t.Errorf("Expected to retry after 60s, but got %ds", openErr.RetryAfterSeconds())
+	}
+}
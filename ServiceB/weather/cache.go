@@ -0,0 +1,90 @@
+package weather
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/EnnioSimoes/2-Observabilidade/ServiceB/cache"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/text/unicode/norm"
+)
+
+// CachedProvider keeps readings for a short freshness window, so CEPs that map
+// to the same city share one upstream call. An entry expires freshness after
+// the reading was observed upstream (WeatherAPI's last_updated_epoch), or
+// freshness after it was fetched when the upstream reading is already older
+// than that.
+type CachedProvider struct {
+	next      WeatherProvider
+	entries   *cache.Cache[string, Reading]
+	freshness time.Duration
+	lookups   metric.Int64Counter
+}
+
+// NewCachedProvider puts a cache of at most size locations in front of next.
+func NewCachedProvider(next WeatherProvider, size int, freshness time.Duration) *CachedProvider {
+	lookups, _ := otel.Meter("service-b").Int64Counter("weather.cache.lookups",
+		metric.WithDescription("Weather lookups served by the weather cache, by result (hit or miss)."),
+	)
+	return &CachedProvider{
+		next:      next,
+		entries:   cache.New[string, Reading](size),
+		freshness: freshness,
+		lookups:   lookups,
+	}
+}
+
+func (c *CachedProvider) Name() string {
+	return c.next.Name()
+}
+
+func (c *CachedProvider) GetWeather(city string, ctx context.Context) (*Reading, error) {
+	span := trace.SpanFromContext(ctx)
+	key := normalizeLocation(city)
+
+	if reading, ok := c.entries.Get(key); ok {
+		c.record(ctx, span, "hit")
+		return &reading, nil
+	}
+	c.record(ctx, span, "miss")
+
+	reading, err := c.next.GetWeather(city, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if reading.ObservedAt.IsZero() {
+		reading.ObservedAt = now
+	}
+
+	ttl := c.freshness - now.Sub(reading.ObservedAt)
+	if ttl <= 0 {
+		ttl = c.freshness
+	}
+	c.entries.Set(key, *reading, ttl)
+	return reading, nil
+}
+
+func (c *CachedProvider) record(ctx context.Context, span trace.Span, result string) {
+	span.SetAttributes(attribute.Bool("weather.cache.hit", result == "hit"))
+	c.lookups.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+}
+
+// normalizeLocation folds case, accents and spacing, so "São Paulo" and
+// " sao  paulo" share a cache entry.
+func normalizeLocation(city string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(city)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
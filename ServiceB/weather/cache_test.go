@@ -0,0 +1,84 @@
+package weather
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type stubProvider struct {
+	calls   int
+	reading Reading
+}
+
+func (s *stubProvider) Name() string {
+	return "stub"
+}
+
+func (s *stubProvider) GetWeather(city string, ctx context.Context) (*Reading, error) {
+	s.calls++
+	r := s.reading
+	return &r, nil
+}
+
+func TestNormalizeLocation(t *testing.T) {
+	for _, city := range []string{"São Paulo", " sao  paulo ", "SÃO PAULO"} {
+		if got := normalizeLocation(city); got != "sao paulo" {
+			t.Errorf("Expected %q to normalize to \"sao paulo\", but got %q", city, got)
+		}
+	}
+}
+
+func TestCachedProviderSharesReadingsByLocation(t *testing.T) {
+	stub := &stubProvider{reading: Reading{City: "São Paulo", TempC: 28.5}}
+	c := NewCachedProvider(stub, 10, 10*time.Minute)
+
+	c.GetWeather("São Paulo", context.Background())
+	reading, err := c.GetWeather("sao paulo", context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if stub.calls != 1 {
+		t.Errorf("Expected 1 upstream call, but got %d", stub.calls)
+	}
+	if reading.ObservedAt.IsZero() {
+		t.Errorf("Expected ObservedAt to default to the fetch time")
+	}
+}
+
+func TestCachedProviderExpiresWithObservation(t *testing.T) {
+	stub := &stubProvider{reading: Reading{City: "São Paulo", TempC: 28.5, ObservedAt: time.Now().Add(-150 * time.Millisecond)}}
+	c := NewCachedProvider(stub, 10, 200*time.Millisecond)
+
+	// The reading was observed 150ms before it was fetched, so it is only
+	// fresh for about 50ms more, not for the whole window.
+	c.GetWeather("São Paulo", context.Background())
+	time.Sleep(100 * time.Millisecond)
+	c.GetWeather("São Paulo", context.Background())
+	if stub.calls != 2 {
+		t.Errorf("Expected the entry to expire with the observation, but got %d upstream calls", stub.calls)
+	}
+}
+
+func TestCachedProviderKeepsStaleUpstreamForWholeWindow(t *testing.T) {
+	stub := &stubProvider{reading: Reading{City: "São Paulo", TempC: 28.5, ObservedAt: time.Now().Add(-time.Hour)}}
+	c := NewCachedProvider(stub, 10, time.Minute)
+
+	c.GetWeather("São Paulo", context.Background())
+	c.GetWeather("São Paulo", context.Background())
+	if stub.calls != 1 {
+		t.Errorf("Expected 1 upstream call, but got %d", stub.calls)
+	}
+}
+
+func TestNewTemperatureReportsAge(t *testing.T) {
+	observedAt := time.Now().Add(-90 * time.Second)
+	temp := NewTemperature(&Reading{City: "São Paulo", TempC: 28, ObservedAt: observedAt})
+
+	if temp.Age < 90 || temp.Age > 91 {
+		t.Errorf("Expected Age to be about 90 seconds, but got %d", temp.Age)
+	}
+	if !temp.ObservedAt.Equal(observedAt) {
+		t.Errorf("Expected ObservedAt to be %v, but got %v", observedAt, temp.ObservedAt)
+	}
+}
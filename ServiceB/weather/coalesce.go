@@ -0,0 +1,35 @@
+package weather
+
+import (
+	"context"
+
+	"github.com/EnnioSimoes/2-Observabilidade/ServiceB/coalesce"
+)
+
+// CoalescingProvider lets concurrent lookups of the same location share a
+// single upstream call.
+type CoalescingProvider struct {
+	next  WeatherProvider
+	group coalesce.Group[string, *Reading]
+}
+
+func NewCoalescingProvider(next WeatherProvider) *CoalescingProvider {
+	return &CoalescingProvider{next: next}
+}
+
+func (c *CoalescingProvider) Name() string {
+	return c.next.Name()
+}
+
+func (c *CoalescingProvider) GetWeather(city string, ctx context.Context) (*Reading, error) {
+	reading, err := c.group.Do(ctx, normalizeLocation(city), "SharedGetWeatherSpan", func(ctx context.Context) (*Reading, error) {
+		return c.next.GetWeather(city, ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Every caller gets its own copy of the shared result.
+	shared := *reading
+	return &shared, nil
+}
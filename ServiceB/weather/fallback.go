@@ -0,0 +1,97 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/breaker"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// FallbackProvider tries each provider in priority order and returns the first
+// reading, so an exhausted quota or an unavailable upstream does not fail the
+// request. ErrCityNotFound is authoritative and stops the chain.
+type FallbackProvider struct {
+	providers []WeatherProvider
+	timeout   time.Duration
+	upstreams *telemetry.UpstreamRecorder
+}
+
+// NewFallbackProvider chains providers in the given order. A positive timeout
+// bounds every single attempt, within what is left of the request deadline;
+// once the deadline is spent, the remaining providers are skipped.
+func NewFallbackProvider(timeout time.Duration, providers ...WeatherProvider) *FallbackProvider {
+	return &FallbackProvider{providers: providers, timeout: timeout, upstreams: telemetry.NewUpstreamRecorder("service-b")}
+}
+
+func (f *FallbackProvider) Name() string {
+	return "fallback"
+}
+
+func (f *FallbackProvider) GetWeather(city string, ctx context.Context) (*Reading, error) {
+	// Span da consulta inteira; cada tentativa de provedor vira um span filho
+	ctx, span := otel.Tracer("service-b").Start(ctx, "GetWeatherSpan")
+	defer span.End()
+	span.SetAttributes(attribute.String("weather.city", city))
+
+	var errs []error
+	for i, p := range f.providers {
+		if err := ctx.Err(); err != nil {
+			// O prazo da requisição acabou: não adianta tentar os próximos provedores
+			errs = append(errs, err)
+			break
+		}
+		reading, err := f.attempt(ctx, p, i+1, city)
+		if err == nil {
+			span.SetAttributes(attribute.String("weather.provider", p.Name()))
+			return reading, nil
+		}
+		if errors.Is(err, ErrCityNotFound) {
+			// Cidade desconhecida é uma resposta, não uma falha: os outros
+			// provedores não vão encontrá-la também
+			telemetry.RecordError(span, err)
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+
+	err := fmt.Errorf("no weather provider configured")
+	if openErr := breaker.Soonest(errs); openErr != nil {
+		// Todos os breakers estão abertos: o handler responde 503 com Retry-After
+		err = fmt.Errorf("all weather providers are unavailable: %w", openErr)
+	} else if len(errs) > 0 {
+		err = telemetry.WithCategory(fmt.Errorf("all weather providers failed: %w", errors.Join(errs...)), "upstream_unavailable")
+	}
+	telemetry.RecordError(span, err)
+	return nil, err
+}
+
+// attempt queries a single provider inside its own child span.
+func (f *FallbackProvider) attempt(ctx context.Context, p WeatherProvider, n int, city string) (*Reading, error) {
+	ctx, span := otel.Tracer("service-b").Start(ctx, "GetWeather "+p.Name())
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("weather.provider", p.Name()),
+		attribute.Int("weather.attempt", n),
+	)
+
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	reading, err := p.GetWeather(city, ctx)
+	f.upstreams.Record(ctx, p.Name(), start, err)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return reading, nil
+}
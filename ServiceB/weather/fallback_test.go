@@ -0,0 +1,141 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newWeatherApiStandIn(t *testing.T, status int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error":{"code":2007,"message":"API key has exceeded calls per month quota."}}`))
+			return
+		}
+		if r.URL.Query().Get("q") != "São Paulo" {
+			t.Errorf("Expected q to be São Paulo, but got %s", r.URL.Query().Get("q"))
+		}
+		w.Write([]byte(`{"location":{"name":"Sao Paulo"},"current":{"last_updated_epoch":1700000000,"temp_c":28.5}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newOpenMeteoStandIn(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/search":
+			w.Write([]byte(`{"results":[{"name":"São Paulo","latitude":-23.5475,"longitude":-46.63611}]}`))
+		case "/v1/forecast":
+			if r.URL.Query().Get("latitude") != "-23.5475" {
+				t.Errorf("Expected latitude to be -23.5475, but got %s", r.URL.Query().Get("latitude"))
+			}
+			w.Write([]byte(`{"current":{"time":"2024-05-01T12:00","temperature_2m":0}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWeatherApiProvider(t *testing.T) {
+	p := NewWeatherApiProvider(newWeatherApiStandIn(t, http.StatusOK).URL, newTestKeyPool(t, []string{"key"}, time.Minute), nil)
+
+	reading, err := p.GetWeather("São Paulo", context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if reading.TempC != 28.5 {
+		t.Errorf("Expected TempC to be 28.5, but got %f", reading.TempC)
+	}
+	if reading.ObservedAt.Unix() != 1700000000 {
+		t.Errorf("Expected ObservedAt to be 1700000000, but got %d", reading.ObservedAt.Unix())
+	}
+}
+
+func TestWeatherApiProviderWithoutKey(t *testing.T) {
+	p := NewWeatherApiProvider(newWeatherApiStandIn(t, http.StatusOK).URL, nil, nil)
+
+	if _, err := p.GetWeather("São Paulo", context.Background()); err == nil {
+		t.Errorf("Expected an error without API key, but got none")
+	}
+}
+
+func TestOpenMeteoProvider(t *testing.T) {
+	srv := newOpenMeteoStandIn(t)
+	p := NewOpenMeteoProvider(srv.URL, srv.URL, nil)
+
+	reading, err := p.GetWeather("São Paulo", context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	// 0°C is a valid reading and must not be mistaken for a missing value.
+	if reading.TempC != 0 {
+		t.Errorf("Expected TempC to be 0, but got %f", reading.TempC)
+	}
+	if reading.City != "São Paulo" {
+		t.Errorf("Expected City to be São Paulo, but got %s", reading.City)
+	}
+}
+
+func TestFallbackProviderFallsBackOnQuotaError(t *testing.T) {
+	meteo := newOpenMeteoStandIn(t)
+	f := NewFallbackProvider(0,
+		NewWeatherApiProvider(newWeatherApiStandIn(t, http.StatusForbidden).URL, newTestKeyPool(t, []string{"key"}, time.Minute), nil),
+		NewOpenMeteoProvider(meteo.URL, meteo.URL, nil),
+	)
+
+	reading, err := f.GetWeather("São Paulo", context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if reading.Provider != OpenMeteoName {
+		t.Errorf("Expected Provider to be %s, but got %s", OpenMeteoName, reading.Provider)
+	}
+}
+
+func TestFallbackProviderAllFailing(t *testing.T) {
+	f := NewFallbackProvider(0,
+		NewWeatherApiProvider(newWeatherApiStandIn(t, http.StatusForbidden).URL, newTestKeyPool(t, []string{"key"}, time.Minute), nil),
+	)
+
+	if _, err := f.GetWeather("São Paulo", context.Background()); err == nil {
+		t.Errorf("Expected an error when every provider fails, but got none")
+	}
+}
+
+func TestFallbackProviderStopsWhenCityIsNotFound(t *testing.T) {
+	weatherApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":1006,"message":"No matching location found."}}`))
+	}))
+	defer weatherApi.Close()
+	meteoCalls := 0
+	meteo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		meteoCalls++
+	}))
+	defer meteo.Close()
+
+	f := NewFallbackProvider(0,
+		NewWeatherApiProvider(weatherApi.URL, newTestKeyPool(t, []string{"key"}, time.Minute), nil),
+		NewOpenMeteoProvider(meteo.URL, meteo.URL, nil),
+	)
+
+	_, err := f.GetWeather("Nowhere", context.Background())
+	if !errors.Is(err, ErrCityNotFound) {
+		t.Errorf("Expected ErrCityNotFound, but got %v", err)
+	}
+	if meteoCalls != 0 {
+		t.Errorf("Expected the chain to stop at an unknown city, but Open-Meteo got %d calls", meteoCalls)
+	}
+	if upstreamFailed(err) {
+		t.Errorf("Expected an unknown city not to count as a breaker failure")
+	}
+}
@@ -0,0 +1,138 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrQuotaExhausted is returned when every WeatherAPI key is cooling down
+// after a quota error.
+var ErrQuotaExhausted = telemetry.WithCategory(errors.New("every WeatherAPI key is out of quota"), "quota_exhausted")
+
+// KeyPool hands out WeatherAPI keys in round-robin, skipping the keys that
+// answered with a quota error until their cool-down is over. Keys are only
+// ever identified by their index in logs, spans and metrics. It is safe for
+// concurrent use.
+type KeyPool struct {
+	keys        []string
+	cooldown    time.Duration
+	now         func() time.Time
+	quotaErrors metric.Int64Counter
+	// registration is the callback of the weatherapi.keys.available gauge.
+	registration metric.Registration
+
+	mu    sync.Mutex
+	next  int
+	until []time.Time
+}
+
+// NewKeyPool builds a pool of the non-empty keys. The number of keys
+// available is reported in the weatherapi.keys.available gauge until Close is
+// called.
+func NewKeyPool(keys []string, cooldown time.Duration) *KeyPool {
+	p := &KeyPool{cooldown: cooldown, now: time.Now}
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			p.keys = append(p.keys, key)
+		}
+	}
+	p.until = make([]time.Time, len(p.keys))
+
+	meter := otel.Meter("service-b")
+	p.quotaErrors, _ = meter.Int64Counter("weatherapi.key.quota_errors",
+		metric.WithDescription("Quota errors answered by WeatherAPI, by key index."),
+	)
+	available, _ := meter.Int64ObservableGauge("weatherapi.keys.available",
+		metric.WithDescription("WeatherAPI keys that are not cooling down after a quota error."),
+	)
+	p.registration, _ = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(available, int64(p.Available()))
+		return nil
+	}, available)
+	return p
+}
+
+// Close stops reporting the weatherapi.keys.available gauge of the pool.
+func (p *KeyPool) Close() error {
+	if p.registration == nil {
+		return nil
+	}
+	return p.registration.Unregister()
+}
+
+// Len returns the number of keys in the pool.
+func (p *KeyPool) Len() int {
+	return len(p.keys)
+}
+
+// Available returns the number of keys that are not cooling down.
+func (p *KeyPool) Available() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	n := 0
+	for _, until := range p.until {
+		if !now.Before(until) {
+			n++
+		}
+	}
+	return n
+}
+
+// pick returns the next key that is not cooling down, and its index.
+func (p *KeyPool) pick() (int, string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	for range p.keys {
+		i := p.next
+		p.next = (p.next + 1) % len(p.keys)
+		if !now.Before(p.until[i]) {
+			return i, p.keys[i], true
+		}
+	}
+	return 0, "", false
+}
+
+// exhaust puts the key at index i aside for the cool-down after it answered
+// with a quota error.
+func (p *KeyPool) exhaust(ctx context.Context, i int, status int) {
+	p.mu.Lock()
+	p.until[i] = p.now().Add(p.cooldown)
+	p.mu.Unlock()
+
+	attrs := []attribute.KeyValue{
+		attribute.Int("weatherapi.key.index", i),
+		attribute.Int("http.response.status_code", status),
+	}
+	p.quotaErrors.Add(ctx, 1, metric.WithAttributes(attrs...))
+	trace.SpanFromContext(ctx).AddEvent("weatherapi.key_exhausted", trace.WithAttributes(attrs...))
+	slog.WarnContext(ctx, "WeatherAPI key out of quota", "key_index", i, "status", status, "cooldown", p.cooldown)
+}
+
+// QuotaStatusCodes are the statuses WeatherAPI refuses a key with: 401 for an
+// invalid or disabled key, 403 once its monthly quota is over and 429 when it
+// is being throttled. Retrying them with the same key only spends budget, so
+// they are left to the key rotation instead.
+var QuotaStatusCodes = []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests}
+
+// quotaStatus tells whether err is WeatherAPI refusing a key.
+func quotaStatus(err error) (int, bool) {
+	var statusErr *telemetry.StatusError
+	if !errors.As(err, &statusErr) || !slices.Contains(QuotaStatusCodes, statusErr.StatusCode) {
+		return 0, false
+	}
+	return statusErr.StatusCode, true
+}
@@ -0,0 +1,130 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// newTestKeyPool builds a key pool that is closed when the test ends.
+func newTestKeyPool(t *testing.T, keys []string, cooldown time.Duration) *KeyPool {
+	t.Helper()
+	p := NewKeyPool(keys, cooldown)
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestKeyPoolRoundRobin(t *testing.T) {
+	p := newTestKeyPool(t, []string{"a", " ", "b", "c"}, time.Minute)
+	if p.Len() != 3 {
+		t.Fatalf("Expected blank keys to be dropped, but got %d keys", p.Len())
+	}
+
+	for _, want := range []string{"a", "b", "c", "a"} {
+		if _, key, _ := p.pick(); key != want {
+			t.Errorf("Expected key %s, but got %s", want, key)
+		}
+	}
+}
+
+func TestKeyPoolSkipsKeysCoolingDown(t *testing.T) {
+	now := time.Now()
+	p := newTestKeyPool(t, []string{"a", "b"}, time.Minute)
+	p.now = func() time.Time { return now }
+
+	p.exhaust(context.Background(), 0, http.StatusForbidden)
+	for range 2 {
+		if _, key, _ := p.pick(); key != "b" {
+			t.Errorf("Expected the exhausted key to be skipped, but got %s", key)
+		}
+	}
+	if p.Available() != 1 {
+		t.Errorf("Expected 1 key available, but got %d", p.Available())
+	}
+
+	now = now.Add(time.Minute)
+	if p.Available() != 2 {
+		t.Errorf("Expected the key back after its cool-down, but got %d keys available", p.Available())
+	}
+}
+
+func TestWeatherApiProviderFailsOverToNextKey(t *testing.T) {
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		keys = append(keys, key)
+		if key != "good" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"location":{"name":"Sao Paulo"},"current":{"last_updated_epoch":1700000000,"temp_c":28.5}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	pool := newTestKeyPool(t, []string{"spent", "good"}, time.Minute)
+	p := NewWeatherApiProvider(srv.URL, pool, nil)
+	for range 2 {
+		if _, err := p.GetWeather("São Paulo", context.Background()); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+	}
+	// The spent key is only tried once during its cool-down.
+	if len(keys) != 3 || keys[0] != "spent" || keys[1] != "good" || keys[2] != "good" {
+		t.Errorf("Expected keys [spent good good], but got %v", keys)
+	}
+}
+
+func TestWeatherApiProviderQuotaExhausted(t *testing.T) {
+	pool := newTestKeyPool(t, []string{"a", "b"}, time.Minute)
+	p := NewWeatherApiProvider(newWeatherApiStandIn(t, http.StatusForbidden).URL, pool, nil)
+
+	for range 2 {
+		if _, err := p.GetWeather("São Paulo", context.Background()); !errors.Is(err, ErrQuotaExhausted) {
+			t.Errorf("Expected ErrQuotaExhausted, but got %v", err)
+		}
+	}
+	if pool.Available() != 0 {
+		t.Errorf("Expected no key available, but got %d", pool.Available())
+	}
+}
+
+func TestKeyPoolStopsReportingWhenClosed(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	available := func() []int64 {
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(context.Background(), &rm); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		var values []int64
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if gauge, ok := m.Data.(metricdata.Gauge[int64]); ok && m.Name == "weatherapi.keys.available" {
+					for _, dp := range gauge.DataPoints {
+						values = append(values, dp.Value)
+					}
+				}
+			}
+		}
+		return values
+	}
+
+	p := NewKeyPool([]string{"a", "b"}, time.Minute)
+	if got := available(); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("Expected 2 keys available to be reported, but got %v", got)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if got := available(); len(got) != 0 {
+		t.Errorf("Expected a closed pool to stop reporting, but got %v", got)
+	}
+}
@@ -2,64 +2,54 @@ package weather
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"time"
 
-	"github.com/EnnioSimoes/2-Observabilidade/ServiceB/configs"
-	"go.opentelemetry.io/otel"
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
 )
 
-type Weatherapi struct {
-	Location struct {
-		Name           string  `json:"name"`
-		Region         string  `json:"region"`
-		Country        string  `json:"country"`
-		Lat            float64 `json:"lat"`
-		Lon            float64 `json:"lon"`
-		TzID           string  `json:"tz_id"`
-		LocaltimeEpoch int     `json:"localtime_epoch"`
-		Localtime      string  `json:"localtime"`
-	} `json:"location"`
-	Current struct {
-		LastUpdatedEpoch int     `json:"last_updated_epoch"`
-		LastUpdated      string  `json:"last_updated"`
-		TempC            float64 `json:"temp_c"`
-		TempF            float64 `json:"temp_f"`
-		IsDay            int     `json:"is_day"`
-		Condition        struct {
-			Text string `json:"text"`
-			Icon string `json:"icon"`
-			Code int    `json:"code"`
-		} `json:"condition"`
-		WindMph    float64 `json:"wind_mph"`
-		WindKph    float64 `json:"wind_kph"`
-		WindDegree int     `json:"wind_degree"`
-		WindDir    string  `json:"wind_dir"`
-		PressureMb float64 `json:"pressure_mb"`
-		PressureIn float64 `json:"pressure_in"`
-		PrecipMm   float64 `json:"precip_mm"`
-		PrecipIn   float64 `json:"precip_in"`
-		Humidity   int     `json:"humidity"`
-		Cloud      int     `json:"cloud"`
-		FeelslikeC float64 `json:"feelslike_c"`
-		FeelslikeF float64 `json:"feelslike_f"`
-		WindchillC float64 `json:"windchill_c"`
-		WindchillF float64 `json:"windchill_f"`
-		HeatindexC float64 `json:"heatindex_c"`
-		HeatindexF float64 `json:"heatindex_f"`
-		DewpointC  float64 `json:"dewpoint_c"`
-		DewpointF  float64 `json:"dewpoint_f"`
-		VisKm      float64 `json:"vis_km"`
-		VisMiles   float64 `json:"vis_miles"`
-		Uv         float64 `json:"uv"`
-		GustMph    float64 `json:"gust_mph"`
-		GustKph    float64 `json:"gust_kph"`
-	} `json:"current"`
+// ErrCityNotFound is returned when a provider does not know the city.
+var ErrCityNotFound = telemetry.WithCategory(errors.New("could not find city"), "not_found")
+
+// Reading is the provider-neutral current temperature of a location.
+type Reading struct {
+	City       string
+	TempC      float64
+	ObservedAt time.Time
+	Provider   string
+}
+
+// WeatherProvider fetches the current temperature of a city from a single
+// upstream API.
+type WeatherProvider interface {
+	Name() string
+	GetWeather(city string, ctx context.Context) (*Reading, error)
+}
+
+// Options carries the provider settings read from the configuration. Empty
+// URLs point the providers at their public endpoints, and a nil Client falls
+// back to http.DefaultClient.
+type Options struct {
+	WeatherApiURL         string
+	WeatherApiKeys        *KeyPool
+	OpenMeteoURL          string
+	OpenMeteoGeocodingURL string
+	Client                *http.Client
+}
+
+// NewProvider builds the provider registered under name.
+func NewProvider(name string, opts Options) (WeatherProvider, error) {
+	switch name {
+	case WeatherApiName:
+		return NewWeatherApiProvider(opts.WeatherApiURL, opts.WeatherApiKeys, opts.Client), nil
+	case OpenMeteoName:
+		return NewOpenMeteoProvider(opts.OpenMeteoURL, opts.OpenMeteoGeocodingURL, opts.Client), nil
+	}
+	return nil, fmt.Errorf("unknown weather provider: %s", name)
 }
 
 type Temperature struct {
@@ -67,59 +57,59 @@ type Temperature struct {
 	Temp_C float64 `json:"temp_c"`
 	Temp_K float64 `json:"temp_k"`
 	Temp_F float64 `json:"temp_f"`
-}
-
-func GetWeather(city string, ctx context.Context) (*Temperature, error) {
-	// Intrumenta o span para a chamada interna
-	// Pega o tracer novamente (ou poderia ser passado como argumento)
-	tracer := otel.Tracer("service-b")
-
-	// Inicia um span filho, pois estamos usando o contexto do `helloHandlerSpan`
-	_, span := tracer.Start(ctx, "GetWeatherSpan")
-	defer span.End()
-
-	time.Sleep(1 * time.Second) // Simula algum processamento
 
-	// Desabilitar a verificação do certificado SSL
-	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-
-	config, _ := configs.LoadConfig()
-
-	city = url.QueryEscape(city)
-	// log.Println("https://api.weatherapi.com/v1/current.json?key=" + config.WeatherapiKey + "&q=" + city + "&aqi=no")
+	// ObservedAt is when the upstream measured the temperature and Age how
+	// many seconds ago that was, so clients know how stale the reading is.
+	ObservedAt time.Time `json:"observed_at,omitzero"`
+	Age        int64     `json:"age"`
+}
 
-	resp, error := http.Get("https://api.weatherapi.com/v1/current.json?key=" + config.WeatherapiKey + "&q=" + city + "&aqi=no")
-	if error != nil {
-		return nil, error
+// NewTemperature formats a reading in Celsius, Kelvin and Fahrenheit.
+func NewTemperature(r *Reading) Temperature {
+	t := formatTemparature(r.TempC)
+	t.City = r.City
+	if !r.ObservedAt.IsZero() {
+		t.ObservedAt = r.ObservedAt
+		t.Age = int64(max(time.Since(r.ObservedAt), 0) / time.Second)
 	}
-	defer resp.Body.Close()
+	return t
+}
 
-	body, error := io.ReadAll(resp.Body)
-	if error != nil {
-		return nil, error
+func formatTemparature(celsius float64) Temperature {
+	return Temperature{
+		Temp_C: celsius,
+		Temp_K: celsius + 273,
+		Temp_F: celsius*1.8 + 32,
 	}
+}
 
-	// fmt.Println("Weather: ", string(body))
+// getJSON performs a GET against url and decodes a 200 response into v. Any
+// other status is returned as a *telemetry.StatusError, after decoding what
+// it can of the body into v so providers can read the error it describes.
+func getJSON(ctx context.Context, client *http.Client, url string, v any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %w", err)
+	}
 
-	var w Weatherapi
-	error = json.Unmarshal(body, &w)
-	if error != nil {
-		return nil, error
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
 	}
+	defer resp.Body.Close()
 
-	if w.Current.TempC == 0 {
-		return nil, fmt.Errorf("could not retrieve temperature for city: %s", city)
+	if resp.StatusCode != http.StatusOK {
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(v)
+		return resp.StatusCode, &telemetry.StatusError{StatusCode: resp.StatusCode, Host: req.URL.Host}
 	}
 
-	t := formatTemparature(w.Current.TempC)
-	t.City = w.Location.Name
-	return &t, nil
-}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
 
-func formatTemparature(celsius float64) Temperature {
-	return Temperature{
-		Temp_C: celsius,
-		Temp_K: celsius + 273,
-		Temp_F: celsius*1.8 + 32,
+	if err := json.Unmarshal(body, v); err != nil {
+		return resp.StatusCode, err
 	}
+	return resp.StatusCode, nil
 }
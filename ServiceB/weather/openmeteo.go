@@ -0,0 +1,96 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OpenMeteoName = "openmeteo"
+
+	openMeteoBaseURL          = "https://api.open-meteo.com"
+	openMeteoGeocodingBaseURL = "https://geocoding-api.open-meteo.com"
+)
+
+type openMeteoGeocoding struct {
+	Results []struct {
+		Name      string  `json:"name"`
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	} `json:"results"`
+}
+
+type openMeteoForecast struct {
+	Current struct {
+		Time          string   `json:"time"`
+		Temperature2m *float64 `json:"temperature_2m"`
+	} `json:"current"`
+}
+
+// OpenMeteoProvider reads the current weather from https://open-meteo.com, or
+// any service speaking the same JSON. It needs no API key: the city is first
+// resolved to coordinates through the geocoding API.
+type OpenMeteoProvider struct {
+	baseURL          string
+	geocodingBaseURL string
+	client           *http.Client
+}
+
+func NewOpenMeteoProvider(baseURL string, geocodingBaseURL string, client *http.Client) *OpenMeteoProvider {
+	if baseURL == "" {
+		baseURL = openMeteoBaseURL
+	}
+	if geocodingBaseURL == "" {
+		geocodingBaseURL = openMeteoGeocodingBaseURL
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &OpenMeteoProvider{
+		baseURL:          strings.TrimRight(baseURL, "/"),
+		geocodingBaseURL: strings.TrimRight(geocodingBaseURL, "/"),
+		client:           client,
+	}
+}
+
+func (p *OpenMeteoProvider) Name() string {
+	return OpenMeteoName
+}
+
+func (p *OpenMeteoProvider) GetWeather(city string, ctx context.Context) (*Reading, error) {
+	var g openMeteoGeocoding
+	u := p.geocodingBaseURL + "/v1/search?count=1&language=pt&countryCode=BR&name=" + url.QueryEscape(city)
+	if _, err := getJSON(ctx, p.client, u, &g); err != nil {
+		return nil, err
+	}
+	if len(g.Results) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrCityNotFound, city)
+	}
+	place := g.Results[0]
+
+	var f openMeteoForecast
+	u = p.baseURL + "/v1/forecast?current=temperature_2m" +
+		"&latitude=" + strconv.FormatFloat(place.Latitude, 'f', -1, 64) +
+		"&longitude=" + strconv.FormatFloat(place.Longitude, 'f', -1, 64)
+	if _, err := getJSON(ctx, p.client, u, &f); err != nil {
+		return nil, err
+	}
+	if f.Current.Temperature2m == nil {
+		return nil, fmt.Errorf("could not retrieve temperature for city: %s", city)
+	}
+
+	// Open-Meteo reports times in GMT unless a timezone is requested.
+	observedAt, _ := time.Parse("2006-01-02T15:04", f.Current.Time)
+
+	return &Reading{
+		City:       place.Name,
+		TempC:      *f.Current.Temperature2m,
+		ObservedAt: observedAt,
+		Provider:   OpenMeteoName,
+	}, nil
+}
@@ -0,0 +1,151 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	WeatherApiName = "weatherapi"
+
+	weatherApiBaseURL = "https://api.weatherapi.com"
+
+	// weatherApiNoLocation is the error code WeatherAPI answers, with a 400,
+	// for a city it does not know.
+	weatherApiNoLocation = 1006
+)
+
+type Weatherapi struct {
+	Location struct {
+		Name           string  `json:"name"`
+		Region         string  `json:"region"`
+		Country        string  `json:"country"`
+		Lat            float64 `json:"lat"`
+		Lon            float64 `json:"lon"`
+		TzID           string  `json:"tz_id"`
+		LocaltimeEpoch int     `json:"localtime_epoch"`
+		Localtime      string  `json:"localtime"`
+	} `json:"location"`
+	Current struct {
+		LastUpdatedEpoch int     `json:"last_updated_epoch"`
+		LastUpdated      string  `json:"last_updated"`
+		TempC            float64 `json:"temp_c"`
+		TempF            float64 `json:"temp_f"`
+		IsDay            int     `json:"is_day"`
+		Condition        struct {
+			Text string `json:"text"`
+			Icon string `json:"icon"`
+			Code int    `json:"code"`
+		} `json:"condition"`
+		WindMph    float64 `json:"wind_mph"`
+		WindKph    float64 `json:"wind_kph"`
+		WindDegree int     `json:"wind_degree"`
+		WindDir    string  `json:"wind_dir"`
+		PressureMb float64 `json:"pressure_mb"`
+		PressureIn float64 `json:"pressure_in"`
+		PrecipMm   float64 `json:"precip_mm"`
+		PrecipIn   float64 `json:"precip_in"`
+		Humidity   int     `json:"humidity"`
+		Cloud      int     `json:"cloud"`
+		FeelslikeC float64 `json:"feelslike_c"`
+		FeelslikeF float64 `json:"feelslike_f"`
+		WindchillC float64 `json:"windchill_c"`
+		WindchillF float64 `json:"windchill_f"`
+		HeatindexC float64 `json:"heatindex_c"`
+		HeatindexF float64 `json:"heatindex_f"`
+		DewpointC  float64 `json:"dewpoint_c"`
+		DewpointF  float64 `json:"dewpoint_f"`
+		VisKm      float64 `json:"vis_km"`
+		VisMiles   float64 `json:"vis_miles"`
+		Uv         float64 `json:"uv"`
+		GustMph    float64 `json:"gust_mph"`
+		GustKph    float64 `json:"gust_kph"`
+	} `json:"current"`
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// WeatherApiProvider reads the current weather from https://www.weatherapi.com,
+// moving on to the next key of its pool when one runs out of quota.
+type WeatherApiProvider struct {
+	baseURL string
+	keys    *KeyPool
+	client  *http.Client
+}
+
+func NewWeatherApiProvider(baseURL string, keys *KeyPool, client *http.Client) *WeatherApiProvider {
+	if baseURL == "" {
+		baseURL = weatherApiBaseURL
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if keys == nil {
+		// Pool vazio: sem chave as consultas falham antes de usá-lo, então
+		// não há por que registrar o gauge dele
+		keys = &KeyPool{}
+	}
+	return &WeatherApiProvider{baseURL: strings.TrimRight(baseURL, "/"), keys: keys, client: client}
+}
+
+func (p *WeatherApiProvider) Name() string {
+	return WeatherApiName
+}
+
+func (p *WeatherApiProvider) GetWeather(city string, ctx context.Context) (*Reading, error) {
+	if p.keys.Len() == 0 {
+		return nil, telemetry.WithCategory(errors.New("missing WEATHER_API_KEY"), "configuration")
+	}
+
+	span := trace.SpanFromContext(ctx)
+	var err error
+	for range p.keys.Len() {
+		i, key, ok := p.keys.pick()
+		if !ok {
+			break
+		}
+		span.SetAttributes(attribute.Int("weatherapi.key.index", i))
+
+		var w Weatherapi
+		u := p.baseURL + "/v1/current.json?key=" + url.QueryEscape(key) + "&q=" + url.QueryEscape(city) + "&aqi=no"
+		statusCode, err := getJSON(ctx, p.client, u, &w)
+		// Chave sem cota: tenta a próxima do pool
+		if status, ok := quotaStatus(err); ok {
+			p.keys.exhaust(ctx, i, status)
+			continue
+		}
+		if statusCode == http.StatusBadRequest && w.Error.Code == weatherApiNoLocation {
+			return nil, fmt.Errorf("%w: %s: %s", ErrCityNotFound, city, w.Error.Message)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if w.Location.Name == "" {
+			return nil, fmt.Errorf("%w: %s", ErrCityNotFound, city)
+		}
+
+		return &Reading{
+			City:       w.Location.Name,
+			TempC:      w.Current.TempC,
+			ObservedAt: time.Unix(int64(w.Current.LastUpdatedEpoch), 0).UTC(),
+			Provider:   WeatherApiName,
+		}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQuotaExhausted, err)
+	}
+	return nil, ErrQuotaExhausted
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GetRequestMetadata makes a Signer a credentials.PerRPCCredentials, so every
+// RPC carries a fresh token on behalf of the principal of its context.
+func (s *Signer) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	token, err := s.Sign(Principal(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity lets the tokens travel over plaintext connections
+// inside the private network of the services.
+func (s *Signer) RequireTransportSecurity() bool {
+	return false
+}
+
+// UnaryServerInterceptor is the gRPC counterpart of Middleware: calls without
+// a valid bearer token in the authorization metadata fail with
+// Unauthenticated. It must run inside the otelgrpc stats handler so the
+// outcome lands on the server span.
+func (v *Verifier) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var authorization string
+		if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+		ctx, err := v.authenticate(ctx, authorization)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "unauthorized")
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,139 @@
+// Package auth authenticates the clients of the services: API keys for the
+// public endpoints, and signed service tokens so a service only accepts calls
+// from the services it trusts. The authenticated principal is kept in the
+// request context and recorded on the server span.
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// APIKeyHeader is the header clients send their API key in.
+const APIKeyHeader = "X-API-Key"
+
+// ErrUnauthenticated is recorded on the span of requests without valid
+// credentials.
+var ErrUnauthenticated = telemetry.WithCategory(errors.New("unauthenticated"), "unauthenticated")
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated principal.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// Principal returns the principal authenticated for the request of ctx, or ""
+// when there is none.
+func Principal(ctx context.Context) string {
+	p, _ := ctx.Value(principalKey{}).(string)
+	return p
+}
+
+// KeyStore maps API keys to the principals they belong to. Only the SHA-256
+// of each key is kept.
+type KeyStore struct {
+	principals map[[sha256.Size]byte]string
+}
+
+// LoadKeys builds a store from entries in the form name=key and, when file is
+// not empty, from the lines of file in the same form. Blank lines and lines
+// starting with # are ignored.
+func LoadKeys(entries []string, file string) (*KeyStore, error) {
+	k := &KeyStore{principals: map[[sha256.Size]byte]string{}}
+	for _, entry := range entries {
+		if err := k.add(entry); err != nil {
+			return nil, err
+		}
+	}
+	if file == "" {
+		return k, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading API keys: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if err := k.add(scanner.Text()); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading API keys: %w", err)
+	}
+	return k, nil
+}
+
+func (k *KeyStore) add(entry string) error {
+	entry = strings.TrimSpace(entry)
+	if entry == "" || strings.HasPrefix(entry, "#") {
+		return nil
+	}
+	name, key, ok := strings.Cut(entry, "=")
+	name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+	if !ok || name == "" || key == "" {
+		return errors.New("invalid API key entry, expected name=key")
+	}
+	k.principals[sha256.Sum256([]byte(key))] = name
+	return nil
+}
+
+// Len returns the number of keys in the store.
+func (k *KeyStore) Len() int {
+	return len(k.principals)
+}
+
+// Lookup returns the principal key belongs to.
+func (k *KeyStore) Lookup(key string) (string, bool) {
+	p, ok := k.principals[sha256.Sum256([]byte(key))]
+	return p, ok
+}
+
+// Middleware answers 401 to requests without a known key in APIKeyHeader and
+// records the principal of the others as enduser.id on the server span. It
+// must run inside the telemetry server middleware.
+func (k *KeyStore) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		span := trace.SpanFromContext(ctx)
+
+		principal, ok := k.Lookup(r.Header.Get(APIKeyHeader))
+		if !ok {
+			reason := "invalid API key"
+			if r.Header.Get(APIKeyHeader) == "" {
+				reason = "missing API key"
+			}
+			slog.WarnContext(ctx, "Request rejected", "reason", reason)
+			telemetry.RecordError(span, fmt.Errorf("%w: %s", ErrUnauthenticated, reason))
+			writeUnauthorized(w, r, "")
+			return
+		}
+
+		span.SetAttributes(attribute.String("enduser.id", principal))
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(ctx, principal)))
+	})
+}
+
+func writeUnauthorized(w http.ResponseWriter, r *http.Request, challenge string) {
+	if challenge != "" {
+		w.Header().Set("WWW-Authenticate", challenge)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized", "trace_id": telemetry.TraceID(r.Context())})
+}
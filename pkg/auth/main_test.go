@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadKeysFromEntriesAndFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys")
+	os.WriteFile(file, []byte("# partners\npartner = file-key\n\n"), 0o600)
+
+	k, err := LoadKeys([]string{"mobile=abc", ""}, file)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if k.Len() != 2 {
+		t.Errorf("Expected 2 keys, but got %d", k.Len())
+	}
+	for key, want := range map[string]string{"abc": "mobile", "file-key": "partner"} {
+		if got, ok := k.Lookup(key); !ok || got != want {
+			t.Errorf("Expected key %s to belong to %s, but got %q", key, want, got)
+		}
+	}
+	if _, ok := k.Lookup("unknown"); ok {
+		t.Errorf("Expected an unknown key not to be found")
+	}
+
+	if _, err := LoadKeys([]string{"no-separator"}, ""); err == nil {
+		t.Errorf("Expected an error for an entry without name=key, but got none")
+	}
+}
+
+func TestKeyStoreMiddleware(t *testing.T) {
+	k, _ := LoadKeys([]string{"mobile=abc"}, "")
+	var principal string
+	h := k.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = Principal(r.Context())
+	}))
+
+	for key, want := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "abc": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodPost, "/temperature", nil)
+		req.Header.Set(APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("Expected status %d for key %q, but got %d", want, key, rec.Code)
+		}
+	}
+	if principal != "mobile" {
+		t.Errorf("Expected principal mobile, but got %q", principal)
+	}
+}
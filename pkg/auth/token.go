@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// leeway absorbs the clock skew between services when checking iat and exp.
+const leeway = 30 * time.Second
+
+// tokenHeader is the encoded JOSE header of every token: HS256 is the only
+// algorithm signed or accepted.
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Claims are the claims of a service token, a JWT signed with HMAC-SHA256.
+type Claims struct {
+	// Issuer is the calling service.
+	Issuer string `json:"iss"`
+	// Subject is the principal the call is made on behalf of, if any.
+	Subject   string `json:"sub,omitempty"`
+	Audience  string `json:"aud"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Signer issues the tokens a service sends to another.
+type Signer struct {
+	issuer   string
+	audience string
+	secret   []byte
+	ttl      time.Duration
+	now      func() time.Time
+}
+
+// NewSigner builds a signer for the calls issuer makes to audience. Tokens
+// expire ttl after being issued, one minute when ttl is 0.
+func NewSigner(issuer, audience string, secret []byte, ttl time.Duration) *Signer {
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	return &Signer{issuer: issuer, audience: audience, secret: secret, ttl: ttl, now: time.Now}
+}
+
+// Sign issues a token on behalf of subject, which may be empty.
+func (s *Signer) Sign(subject string) (string, error) {
+	now := s.now()
+	payload, err := json.Marshal(Claims{
+		Issuer:    s.issuer,
+		Subject:   subject,
+		Audience:  s.audience,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sign(s.secret, unsigned)), nil
+}
+
+// Transport wraps base (http.DefaultTransport when nil) so every call carries
+// a fresh token in the Authorization header, on behalf of the principal of the
+// request context.
+func (s *Signer) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{signer: s, base: base}
+}
+
+type transport struct {
+	signer *Signer
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.signer.Sign(Principal(req.Context()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
+
+// Verifier checks the tokens a service receives.
+type Verifier struct {
+	audience string
+	issuers  []string
+	secrets  [][]byte
+	now      func() time.Time
+}
+
+// NewVerifier builds a verifier accepting the tokens issued for audience by
+// any of issuers and signed with any of secrets, so a secret can be rotated
+// without downtime.
+func NewVerifier(audience string, issuers []string, secrets ...[]byte) *Verifier {
+	return &Verifier{audience: audience, issuers: issuers, secrets: secrets, now: time.Now}
+}
+
+// Verify checks the signature, audience, issuer and lifetime of token.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	header, rest, _ := strings.Cut(token, ".")
+	payload, signature, ok := strings.Cut(rest, ".")
+	if !ok || header != tokenHeader {
+		return nil, errors.New("malformed token")
+	}
+	got, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, errors.New("malformed token")
+	}
+	if !slices.ContainsFunc(v.secrets, func(secret []byte) bool {
+		return hmac.Equal(got, sign(secret, header+"."+payload))
+	}) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errors.New("malformed token")
+	}
+	var claims Claims
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return nil, errors.New("malformed token")
+	}
+
+	now := v.now()
+	switch {
+	case claims.Audience != v.audience:
+		return nil, fmt.Errorf("token is for %q", claims.Audience)
+	case !slices.Contains(v.issuers, claims.Issuer):
+		return nil, fmt.Errorf("untrusted token issuer %q", claims.Issuer)
+	case now.Add(-leeway).Unix() >= claims.ExpiresAt:
+		return nil, errors.New("token expired")
+	case now.Add(leeway).Unix() < claims.IssuedAt:
+		return nil, errors.New("token issued in the future")
+	}
+	return &claims, nil
+}
+
+// Middleware answers 401 to requests without a valid bearer token. It records
+// the calling service as auth.principal on the server span, and the principal
+// it called on behalf of as enduser.id. It must run inside the telemetry
+// server middleware.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := v.authenticate(r.Context(), r.Header.Get("Authorization"))
+		if err != nil {
+			challenge := `Bearer realm="` + v.audience + `"`
+			if !errors.Is(err, errMissingToken) {
+				challenge += `, error="invalid_token"`
+			}
+			writeUnauthorized(w, r, challenge)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+var errMissingToken = errors.New("missing service token")
+
+// authenticate verifies the bearer token in authorization, recording the
+// outcome on the span of ctx, and returns ctx with the calling service as
+// principal.
+func (v *Verifier) authenticate(ctx context.Context, authorization string) (context.Context, error) {
+	span := trace.SpanFromContext(ctx)
+
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		slog.WarnContext(ctx, "Request rejected", "reason", errMissingToken)
+		telemetry.RecordError(span, fmt.Errorf("%w: %w", ErrUnauthenticated, errMissingToken))
+		return ctx, errMissingToken
+	}
+	claims, err := v.Verify(token)
+	if err != nil {
+		slog.WarnContext(ctx, "Request rejected", "reason", err)
+		telemetry.RecordError(span, fmt.Errorf("%w: %w", ErrUnauthenticated, err))
+		return ctx, err
+	}
+
+	span.SetAttributes(attribute.String("auth.principal", claims.Issuer))
+	if claims.Subject != "" {
+		span.SetAttributes(attribute.String("enduser.id", claims.Subject))
+	}
+	return WithPrincipal(ctx, claims.Issuer), nil
+}
+
+func sign(secret []byte, unsigned string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyAcceptsSignedToken(t *testing.T) {
+	token, err := NewSigner("service-a", "service-b", []byte("secret"), time.Minute).Sign("mobile")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	// The old secret is still accepted while it is being rotated out.
+	claims, err := NewVerifier("service-b", []string{"service-a"}, []byte("new"), []byte("secret")).Verify(token)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if claims.Issuer != "service-a" || claims.Subject != "mobile" {
+		t.Errorf("Expected issuer service-a and subject mobile, but got %s and %s", claims.Issuer, claims.Subject)
+	}
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	signer := NewSigner("service-a", "service-b", []byte("secret"), time.Minute)
+	valid, _ := signer.Sign("")
+
+	expired := NewSigner("service-a", "service-b", []byte("secret"), time.Minute)
+	expired.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	old, _ := expired.Sign("")
+
+	other, _ := NewSigner("service-a", "service-c", []byte("secret"), time.Minute).Sign("")
+	untrusted, _ := NewSigner("intruder", "service-b", []byte("secret"), time.Minute).Sign("")
+	forged, _ := NewSigner("service-a", "service-b", []byte("guess"), time.Minute).Sign("")
+	parts := strings.Split(valid, ".")
+	none := strings.Join([]string{"eyJhbGciOiJub25lIn0", parts[1], ""}, ".")
+
+	v := NewVerifier("service-b", []string{"service-a"}, []byte("secret"))
+	for name, token := range map[string]string{
+		"expired":         old,
+		"wrong audience":  other,
+		"untrusted":       untrusted,
+		"wrong secret":    forged,
+		"alg none":        none,
+		"malformed":       "not-a-token",
+		"empty signature": parts[0] + "." + parts[1] + ".",
+	} {
+		if _, err := v.Verify(token); err == nil {
+			t.Errorf("Expected the %s token to be rejected, but it was accepted", name)
+		}
+	}
+}
+
+func TestSignerTransportAndVerifierMiddleware(t *testing.T) {
+	var principal string
+	srv := httptest.NewServer(NewVerifier("service-b", []string{"service-a"}, []byte("secret")).Middleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal = Principal(r.Context())
+		}),
+	))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected 401 with a challenge without a token, but got %d", resp.StatusCode)
+	}
+
+	client := &http.Client{Transport: NewSigner("service-a", "service-b", []byte("secret"), time.Minute).Transport(nil)}
+	req, _ := http.NewRequestWithContext(WithPrincipal(t.Context(), "mobile"), http.MethodGet, srv.URL, nil)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || principal != "service-a" {
+		t.Errorf("Expected 200 from service-a, but got %d from %q", resp.StatusCode, principal)
+	}
+}
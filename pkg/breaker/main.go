@@ -0,0 +1,251 @@
+// Package breaker implements circuit breakers that stop calling an upstream
+// that keeps failing, so requests fail fast instead of waiting for it, and
+// let a few probes through after a cool-down to find out when it is back.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// State is the position of a breaker.
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// HalfOpen lets a limited number of probes through.
+	HalfOpen
+	// Open rejects every call.
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half_open"
+	case Open:
+		return "open"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Settings tunes a breaker. Zero values fall back to 5 failures, 30s and 1
+// probe.
+type Settings struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// breaker.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before probing.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of probes let through while half open;
+	// the breaker closes once all of them succeed.
+	HalfOpenRequests int
+}
+
+// OpenError is returned while a breaker rejects calls.
+type OpenError struct {
+	Name string
+	// RetryAfter is how long until the breaker lets calls through again.
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for %s is open", e.Name)
+}
+
+func (e *OpenError) Category() string {
+	return "circuit_open"
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds, at least one, for
+// the Retry-After header.
+func (e *OpenError) RetryAfterSeconds() int {
+	return max(int(math.Ceil(e.RetryAfter.Seconds())), 1)
+}
+
+// Soonest returns the *OpenError that lets calls through first when every one
+// of errs was refused by an open breaker, and nil otherwise. A fallback chain
+// uses it to tell "every upstream is shut" apart from ordinary failures.
+func Soonest(errs []error) *OpenError {
+	var soonest *OpenError
+	for _, err := range errs {
+		var openErr *OpenError
+		if !errors.As(err, &openErr) {
+			return nil
+		}
+		if soonest == nil || openErr.RetryAfter < soonest.RetryAfter {
+			soonest = openErr
+		}
+	}
+	return soonest
+}
+
+// Refused returns the *OpenError err was wrapped around, looking only down its
+// direct chain: an open breaker joined with other failures, as in
+// errors.Join, does not make the whole call refused.
+func Refused(err error) (*OpenError, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if openErr, ok := err.(*OpenError); ok {
+			return openErr, true
+		}
+	}
+	return nil, false
+}
+
+// Breaker guards the calls to one upstream. It is safe for concurrent use.
+type Breaker struct {
+	name     string
+	settings Settings
+	now      func() time.Time
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+	// generation changes whenever the breaker opens or starts probing, so
+	// calls let through before that cannot count towards the new state.
+	generation uint64
+}
+
+// New builds a closed breaker for the upstream name and reports its state
+// in the circuit_breaker.state gauge of the meter meterName.
+func New(meterName, name string, settings Settings) *Breaker {
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = 5
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = 30 * time.Second
+	}
+	if settings.HalfOpenRequests <= 0 {
+		settings.HalfOpenRequests = 1
+	}
+	b := &Breaker{name: name, settings: settings, now: time.Now}
+
+	otel.Meter(meterName).Int64ObservableGauge("circuit_breaker.state",
+		metric.WithDescription("State of the circuit breaker of each upstream: 0 closed, 1 half open, 2 open."),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(b.State()), metric.WithAttributes(attribute.String("upstream", name)))
+			return nil
+		}),
+	)
+	return b
+}
+
+// Name returns the upstream the breaker guards.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state, moving an open breaker to half open once
+// its timeout has passed.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	return b.state
+}
+
+// Allow asks to make one call, tagging the span in ctx with the breaker
+// state. It returns an *OpenError when the call must not be made; otherwise
+// done must be called with whether the call failed. A call whose ctx was
+// canceled says nothing about the upstream: it is not counted, and a probe
+// gives its place back to the next call.
+func (b *Breaker) Allow(ctx context.Context) (done func(failed bool), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("circuit_breaker.name", b.name),
+		attribute.String("circuit_breaker.state", b.state.String()),
+	)
+
+	switch b.state {
+	case Open:
+		return nil, &OpenError{Name: b.name, RetryAfter: b.openedAt.Add(b.settings.OpenTimeout).Sub(b.now())}
+	case HalfOpen:
+		if b.probes >= b.settings.HalfOpenRequests {
+			return nil, &OpenError{Name: b.name, RetryAfter: time.Second}
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	var once sync.Once
+	return func(failed bool) {
+		once.Do(func() { b.record(generation, failed, errors.Is(ctx.Err(), context.Canceled)) })
+	}, nil
+}
+
+// record counts the outcome of a call let through in generation, ignoring
+// calls that finish after the breaker has moved on.
+func (b *Breaker) record(generation uint64, failed, canceled bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+	if canceled {
+		if b.state == HalfOpen {
+			b.probes--
+		}
+		return
+	}
+
+	switch b.state {
+	case Closed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.settings.FailureThreshold {
+			b.open()
+		}
+	case HalfOpen:
+		if failed {
+			b.open()
+			return
+		}
+		b.successes++
+		if b.successes >= b.settings.HalfOpenRequests {
+			b.state = Closed
+			b.failures = 0
+			slog.Info("Circuit breaker closed", "upstream", b.name)
+		}
+	}
+}
+
+func (b *Breaker) open() {
+	b.state = Open
+	b.openedAt = b.now()
+	b.generation++
+	slog.Warn("Circuit breaker opened", "upstream", b.name, "open_timeout", b.settings.OpenTimeout)
+}
+
+// refresh moves an open breaker to half open once its timeout has passed.
+// It must be called with mu held.
+func (b *Breaker) refresh() {
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.settings.OpenTimeout {
+		b.state = HalfOpen
+		b.probes = 0
+		b.successes = 0
+		b.generation++
+	}
+}
@@ -0,0 +1,179 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newTestBreaker returns a breaker whose clock only moves with the returned
+// function.
+func newTestBreaker(settings Settings) (*Breaker, func(time.Duration)) {
+	now := time.Now()
+	b := New("test", "upstream", settings)
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }
+}
+
+func call(t *testing.T, b *Breaker, failed bool) error {
+	t.Helper()
+	done, err := b.Allow(context.Background())
+	if err != nil {
+		return err
+	}
+	done(failed)
+	return nil
+}
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	b, _ := newTestBreaker(Settings{FailureThreshold: 3, OpenTimeout: time.Minute})
+
+	call(t, b, true)
+	call(t, b, true)
+	call(t, b, false)
+	call(t, b, true)
+	call(t, b, true)
+	if b.State() != Closed {
+		t.Fatalf("Expected a success to reset the failure count, but got %s", b.State())
+	}
+
+	call(t, b, true)
+	if b.State() != Open {
+		t.Fatalf("Expected the breaker to open after 3 consecutive failures, but got %s", b.State())
+	}
+
+	var openErr *OpenError
+	if err := call(t, b, false); !errors.As(err, &openErr) {
+		t.Fatalf("Expected an *OpenError, but got %v", err)
+	}
+	if openErr.RetryAfter != time.Minute || openErr.RetryAfterSeconds() != 60 {
+		t.Errorf("Expected to retry after 60s, but got %s", openErr.RetryAfter)
+	}
+}
+
+func TestBreakerProbesWhenHalfOpen(t *testing.T) {
+	b, advance := newTestBreaker(Settings{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenRequests: 2})
+
+	call(t, b, true)
+	advance(time.Minute)
+	if b.State() != HalfOpen {
+		t.Fatalf("Expected the breaker to be half open after its timeout, but got %s", b.State())
+	}
+
+	first, err := b.Allow(context.Background())
+	if err != nil {
+		t.Fatalf("Expected the first probe to go through, but got %v", err)
+	}
+	second, err := b.Allow(context.Background())
+	if err != nil {
+		t.Fatalf("Expected the second probe to go through, but got %v", err)
+	}
+	if _, err := b.Allow(context.Background()); err == nil {
+		t.Fatalf("Expected a third call to be rejected while probing")
+	}
+
+	first(false)
+	if b.State() != HalfOpen {
+		t.Errorf("Expected the breaker to wait for every probe, but got %s", b.State())
+	}
+	second(false)
+	if b.State() != Closed {
+		t.Errorf("Expected the breaker to close after successful probes, but got %s", b.State())
+	}
+}
+
+func TestBreakerReopensOnFailedProbe(t *testing.T) {
+	b, advance := newTestBreaker(Settings{FailureThreshold: 1, OpenTimeout: time.Minute})
+
+	call(t, b, true)
+	advance(time.Minute)
+	call(t, b, true)
+	if b.State() != Open {
+		t.Errorf("Expected a failed probe to reopen the breaker, but got %s", b.State())
+	}
+	advance(30 * time.Second)
+	if b.State() != Open {
+		t.Errorf("Expected the breaker to stay open for a full timeout, but got %s", b.State())
+	}
+}
+
+func TestBreakerIgnoresRepeatedDone(t *testing.T) {
+	b, _ := newTestBreaker(Settings{FailureThreshold: 2})
+
+	done, _ := b.Allow(context.Background())
+	done(true)
+	done(true)
+	if b.State() != Closed {
+		t.Errorf("Expected a call to count once, but got %s", b.State())
+	}
+}
+
+func TestBreakerIgnoresCallsFromAnEarlierState(t *testing.T) {
+	b, advance := newTestBreaker(Settings{FailureThreshold: 1, OpenTimeout: time.Minute})
+
+	late, err := b.Allow(context.Background())
+	if err != nil {
+		t.Fatalf("Expected the call to go through while closed, but got %v", err)
+	}
+	call(t, b, true)
+	advance(time.Minute)
+	if b.State() != HalfOpen {
+		t.Fatalf("Expected the breaker to be half open after its timeout, but got %s", b.State())
+	}
+
+	late(false)
+	if b.State() != HalfOpen {
+		t.Errorf("Expected a call started while closed not to count as a probe, but got %s", b.State())
+	}
+	if err := call(t, b, false); err != nil {
+		t.Fatalf("Expected the probe to go through, but got %v", err)
+	}
+	if b.State() != Closed {
+		t.Errorf("Expected the probe to close the breaker, but got %s", b.State())
+	}
+}
+
+func TestBreakerFreesTheProbeOfACanceledCall(t *testing.T) {
+	b, advance := newTestBreaker(Settings{FailureThreshold: 1, OpenTimeout: time.Minute})
+
+	call(t, b, true)
+	advance(time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done, err := b.Allow(ctx)
+	if err != nil {
+		t.Fatalf("Expected the probe to go through, but got %v", err)
+	}
+	cancel()
+	done(false)
+	if b.State() != HalfOpen {
+		t.Errorf("Expected a canceled probe not to close the breaker, but got %s", b.State())
+	}
+	if err := call(t, b, false); err != nil {
+		t.Fatalf("Expected the next probe to take the freed place, but got %v", err)
+	}
+	if b.State() != Closed {
+		t.Errorf("Expected the probe to close the breaker, but got %s", b.State())
+	}
+}
+
+func TestSoonestOnlyWhenEveryCallWasRefused(t *testing.T) {
+	first := &OpenError{Name: "first", RetryAfter: time.Minute}
+	second := &OpenError{Name: "second", RetryAfter: time.Second}
+
+	if got := Soonest([]error{first, fmt.Errorf("second: %w", second)}); got != second {
+		t.Errorf("Expected the breaker that reopens first, but got %v", got)
+	}
+	if got := Soonest([]error{first, errors.New("bad gateway")}); got != nil {
+		t.Errorf("Expected no *OpenError with an ordinary failure, but got %v", got)
+	}
+
+	if _, ok := Refused(fmt.Errorf("all failed: %w", errors.Join(first, errors.New("bad gateway")))); ok {
+		t.Errorf("Expected a joined open breaker not to refuse the call")
+	}
+	if got, ok := Refused(fmt.Errorf("all unavailable: %w", second)); !ok || got != second {
+		t.Errorf("Expected the wrapped *OpenError, but got %v", got)
+	}
+}
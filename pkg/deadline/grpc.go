@@ -0,0 +1,36 @@
+package deadline
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// NewUnaryServerInterceptor is the gRPC counterpart of NewMiddleware. gRPC
+// already carries the deadline of the caller, so calls are bounded by what is
+// left of it, capped at limit, or by budget when the caller set none; a
+// budget of zero or less leaves those calls without a deadline. It must run
+// inside the otelgrpc stats handler so the budget lands on the server span.
+func NewUnaryServerInterceptor(budget, limit time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		timeout := budget
+		d, ok := ctx.Deadline()
+		if ok {
+			timeout = time.Until(d)
+		} else if budget <= 0 {
+			return handler(ctx, req)
+		}
+		if limit > 0 && timeout > limit {
+			timeout = limit
+		}
+
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("request.timeout_ms", timeout.Milliseconds()))
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
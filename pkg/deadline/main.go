@@ -0,0 +1,92 @@
+// Package deadline gives every request a time budget that is enforced through
+// its context and handed on to the next hop in the X-Request-Timeout header,
+// so a service never keeps working on a request its caller gave up on.
+package deadline
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header carries the remaining budget of a request, in milliseconds.
+const Header = "X-Request-Timeout"
+
+// maxMillis is the longest budget in milliseconds a time.Duration can hold.
+const maxMillis = math.MaxInt64 / int64(time.Millisecond)
+
+// ErrExceeded is reported when a request runs out of its budget.
+var ErrExceeded = telemetry.WithCategory(errors.New("request deadline exceeded"), "deadline_exceeded")
+
+// Exceeded reports whether ctx ran out of its budget, as opposed to failing
+// for any other reason.
+func Exceeded(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// NewMiddleware bounds every request by the budget the caller sent in Header,
+// capped at limit, or by budget when the caller sent none. A budget of zero or
+// less leaves such requests without a deadline. It must run inside the
+// telemetry server middleware so the budget lands on the server span.
+func NewMiddleware(budget, limit time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := budget
+			if ms, err := strconv.ParseInt(r.Header.Get(Header), 10, 64); err == nil && ms > 0 {
+				// Clamp before converting, or a huge header would overflow
+				// into a negative timeout.
+				timeout = time.Duration(min(ms, maxMillis)) * time.Millisecond
+			}
+			if limit > 0 && timeout > limit {
+				timeout = limit
+			}
+			if timeout <= 0 {
+				// No budget configured or sent: the request has no deadline
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.Int64("request.timeout_ms", timeout.Milliseconds()))
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// NewTransport wraps base (http.DefaultTransport when nil) so every request
+// with a deadline tells the next hop how much of it is left. Requests whose
+// budget is already spent fail without reaching the network.
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	d, ok := req.Context().Deadline()
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	remaining := time.Until(d).Milliseconds()
+	if remaining <= 0 {
+		return nil, context.DeadlineExceeded
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set(Header, strconv.FormatInt(remaining, 10))
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,139 @@
+package deadline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// budgetOf runs the middleware and returns the budget the handler saw.
+func budgetOf(t *testing.T, mw func(http.Handler) http.Handler, header string) time.Duration {
+	t.Helper()
+	var got time.Duration
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, ok := r.Context().Deadline()
+		if !ok {
+			t.Fatalf("Expected the request context to have a deadline")
+		}
+		got = time.Until(d)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		req.Header.Set(Header, header)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	return got
+}
+
+func TestMiddlewareHonorsCallerBudget(t *testing.T) {
+	mw := NewMiddleware(5*time.Second, 10*time.Second)
+
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 5 * time.Second},
+		{"1500", 1500 * time.Millisecond},
+		{"60000", 10 * time.Second},
+		{"soon", 5 * time.Second},
+		{"-1", 5 * time.Second},
+		{"9223372036854775807", 10 * time.Second},
+		{"99999999999999999999", 5 * time.Second},
+	}
+	for _, tt := range tests {
+		got := budgetOf(t, mw, tt.header)
+		if got > tt.want || got < tt.want-100*time.Millisecond {
+			t.Errorf("Expected a budget of about %s for header %q, but got %s", tt.want, tt.header, got)
+		}
+	}
+}
+
+func TestMiddlewareClampsHugeBudgetWithoutLimit(t *testing.T) {
+	got := budgetOf(t, NewMiddleware(5*time.Second, 0), "9223372036854775807")
+	if got < 24*time.Hour {
+		t.Errorf("Expected a huge header to give a long budget, but got %s", got)
+	}
+}
+
+func TestMiddlewareWithoutBudgetSetsNoDeadline(t *testing.T) {
+	for _, budget := range []time.Duration{0, -time.Second} {
+		served := false
+		h := NewMiddleware(budget, 10*time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served = true
+			if err := r.Context().Err(); err != nil {
+				t.Errorf("Expected a live context with budget %s, but got %v", budget, err)
+			}
+			if _, ok := r.Context().Deadline(); ok {
+				t.Errorf("Expected no deadline with budget %s", budget)
+			}
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		if !served {
+			t.Errorf("Expected the request to be served with budget %s", budget)
+		}
+	}
+
+	if got := budgetOf(t, NewMiddleware(0, 10*time.Second), "1500"); got > 1500*time.Millisecond || got < 1400*time.Millisecond {
+		t.Errorf("Expected the caller budget to still apply, but got %s", got)
+	}
+}
+
+func TestTransportForwardsRemainingBudget(t *testing.T) {
+	var received string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(Header)
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: NewTransport(nil)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	ms, _ := strconv.Atoi(received)
+	if ms <= 1500 || ms > 2000 {
+		t.Errorf("Expected the upstream to receive about 2000ms, but got %q", received)
+	}
+	if req.Header.Get(Header) != "" {
+		t.Errorf("Expected the caller's request to be left untouched")
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, upstream.URL, nil)
+	received = "unset"
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	if received != "" {
+		t.Errorf("Expected no budget header without a deadline, but got %q", received)
+	}
+}
+
+func TestTransportFailsSpentBudget(t *testing.T) {
+	called := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	defer upstream.Close()
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	_, err := NewTransport(nil).RoundTrip(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, but got %v", err)
+	}
+	if called {
+		t.Errorf("Expected the upstream not to be called")
+	}
+	if !Exceeded(ctx) {
+		t.Errorf("Expected Exceeded to report the spent budget")
+	}
+}
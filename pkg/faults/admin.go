@@ -0,0 +1,54 @@
+package faults
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// Handler serves the admin endpoint: GET lists the active rules, PUT replaces
+// them with the JSON array in the body and DELETE removes them all.
+func (in *Injector) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeAdminError(w, http.StatusBadRequest, err)
+				return
+			}
+			rules, err := ParseRules(body)
+			if err == nil {
+				err = in.SetRules(rules)
+			}
+			if err != nil {
+				writeAdminError(w, http.StatusBadRequest, err)
+				return
+			}
+			slog.WarnContext(r.Context(), "Fault injection rules replaced", "rules", len(rules))
+		case http.MethodDelete:
+			in.SetRules(nil)
+			slog.InfoContext(r.Context(), "Fault injection rules removed")
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			writeAdminError(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(in.Rules())
+	})
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	message := http.StatusText(status)
+	if err != nil {
+		message = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,109 @@
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+)
+
+// ErrInjected is returned by the transport for injected connection errors.
+var ErrInjected = telemetry.WithCategory(errors.New("injected fault"), "fault_injection")
+
+// Middleware injects the faults of the first rule whose route matches the
+// request path. It must run inside the telemetry server middleware so the
+// faults land on the server span. Paths under /admin/ are never touched, so a
+// catch-all rule cannot lock the admin endpoint out.
+func (in *Injector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rule, ok := in.matchRoute(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		outcome, err := in.apply(r.Context(), rule, r.URL.Path)
+		switch {
+		case err != nil:
+			// The caller gave up while the injected latency was running.
+			writeFault(w, r, http.StatusGatewayTimeout, "injected latency exceeded the request deadline")
+		case outcome == hang:
+			writeFault(w, r, http.StatusGatewayTimeout, "injected timeout")
+		case outcome == fail:
+			status := rule.Status
+			if status == 0 {
+				status = http.StatusInternalServerError
+			}
+			writeFault(w, r, status, "injected fault")
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func writeFault(w http.ResponseWriter, r *http.Request, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message, "trace_id": telemetry.TraceID(r.Context())})
+}
+
+// Transport wraps base (http.DefaultTransport when nil) and injects the
+// faults of the first rule whose upstream matches the request host. Wrap it
+// with telemetry.NewTransport so the faults land on the client span.
+func (in *Injector) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{injector: in, base: base}
+}
+
+const injectedBody = "injected fault"
+
+type transport struct {
+	injector *Injector
+	base     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	rule, ok := t.injector.matchUpstream(host)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	outcome, err := t.injector.apply(req.Context(), rule, host)
+	switch {
+	case err != nil:
+		return nil, err
+	case outcome == hang:
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %s did not answer in %s", context.DeadlineExceeded, host, time.Duration(rule.Timeout))
+	case outcome == fail && rule.Status == 0:
+		return nil, fmt.Errorf("%w: connection to %s failed", ErrInjected, host)
+	case outcome == fail:
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", rule.Status, http.StatusText(rule.Status)),
+			StatusCode:    rule.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"text/plain"}},
+			Body:          io.NopCloser(strings.NewReader(injectedBody)),
+			ContentLength: int64(len(injectedBody)),
+			Request:       req,
+		}, nil
+	}
+	return t.base.RoundTrip(req)
+}
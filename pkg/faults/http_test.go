@@ -0,0 +1,181 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan returns a context carrying a recording span, and the recorder
+// that holds it once ended.
+func startSpan(t *testing.T) (context.Context, trace.Span, *tracetest.SpanRecorder) {
+	t.Helper()
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	ctx, span := tp.Tracer("test").Start(context.Background(), "TestSpan")
+	return ctx, span, sr
+}
+
+func faultKinds(t *testing.T, sr *tracetest.SpanRecorder) []string {
+	t.Helper()
+	ended := sr.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("Expected 1 ended span, but got %d", len(ended))
+	}
+	var kinds []string
+	for _, e := range ended[0].Events() {
+		if e.Name != "fault.injected" {
+			continue
+		}
+		for _, kv := range e.Attributes {
+			if kv.Key == attribute.Key("fault.kind") {
+				kinds = append(kinds, kv.Value.AsString())
+			}
+		}
+	}
+	return kinds
+}
+
+func TestMiddlewareInjectsStatus(t *testing.T) {
+	in := New()
+	in.SetRules([]Rule{{Route: "/temperature/{cep}", ErrorRate: 1, Status: http.StatusServiceUnavailable}})
+	called := false
+	h := in.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	ctx, span, sr := startSpan(t)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/temperature/01001000", nil).WithContext(ctx))
+	span.End()
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, but got %d", rec.Code)
+	}
+	if called {
+		t.Errorf("Expected the handler not to run on an injected error")
+	}
+	if kinds := faultKinds(t, sr); len(kinds) != 1 || kinds[0] != "error" {
+		t.Errorf("Expected one error fault event, but got %v", kinds)
+	}
+}
+
+func TestMiddlewareSkipsAdminAndUnmatchedRoutes(t *testing.T) {
+	in := New()
+	in.SetRules([]Rule{{Route: "*", ErrorRate: 1}})
+	h := in.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/faults", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the admin endpoint to be spared, but got %d", rec.Code)
+	}
+
+	in.SetRules([]Rule{{Route: "/temperature/{cep}", ErrorRate: 1}})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/temperature", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected unmatched routes to pass through, but got %d", rec.Code)
+	}
+}
+
+func TestMiddlewareInjectsLatencyAndTimeout(t *testing.T) {
+	in := New()
+	in.SetRules([]Rule{{Route: "*", Latency: Duration(20 * time.Millisecond), TimeoutRate: 1, Timeout: Duration(20 * time.Millisecond)}})
+	h := in.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	ctx, span, sr := startSpan(t)
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/temperature", nil).WithContext(ctx))
+	elapsed := time.Since(start)
+	span.End()
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status 504, but got %d", rec.Code)
+	}
+	if elapsed < 40*time.Millisecond {
+		t.Errorf("Expected the request to take at least 40ms, but took %s", elapsed)
+	}
+	if kinds := faultKinds(t, sr); strings.Join(kinds, ",") != "latency,timeout" {
+		t.Errorf("Expected latency and timeout fault events, but got %v", kinds)
+	}
+}
+
+func TestTransportInjectsUpstreamFaults(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	in := New()
+	client := &http.Client{Transport: in.Transport(nil)}
+	get := func(ctx context.Context) (*http.Response, error) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+		return client.Do(req)
+	}
+
+	in.SetRules([]Rule{{Upstream: "127.0.0.1", ErrorRate: 1, Status: http.StatusTooManyRequests}})
+	ctx, span, sr := startSpan(t)
+	resp, err := get(ctx)
+	span.End()
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, but got %d", resp.StatusCode)
+	}
+	if kinds := faultKinds(t, sr); len(kinds) != 1 || kinds[0] != "error" {
+		t.Errorf("Expected one error fault event, but got %v", kinds)
+	}
+
+	in.SetRules([]Rule{{Upstream: "127.0.0.1", ErrorRate: 1}})
+	if _, err := get(context.Background()); !errors.Is(err, ErrInjected) {
+		t.Errorf("Expected ErrInjected, but got %v", err)
+	}
+
+	in.SetRules([]Rule{{Upstream: "127.0.0.1", TimeoutRate: 1, Timeout: Duration(time.Minute)}})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the caller's deadline to cut the injected timeout, but got %v", err)
+	}
+
+	in.SetRules([]Rule{{Upstream: "viacep.com.br", ErrorRate: 1}})
+	resp, err = get(context.Background())
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected other upstreams to pass through, but got %v, %v", resp, err)
+	}
+}
+
+func TestHandlerReplacesRules(t *testing.T) {
+	in := New()
+	h := in.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/faults", strings.NewReader(`[{"route":"*","error_rate":0.5,"status":503}]`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, but got %d: %s", rec.Code, rec.Body)
+	}
+	if rules := in.Rules(); len(rules) != 1 || rules[0].Status != 503 {
+		t.Errorf("Expected the new rule to be active, but got %v", rules)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/faults", strings.NewReader(`[{"route":"*","error_rate":2}]`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid rule, but got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/faults", nil))
+	if got := strings.TrimSpace(rec.Body.String()); got != "[]" {
+		t.Errorf("Expected no rules after DELETE, but got %s", got)
+	}
+}
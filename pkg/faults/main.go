@@ -0,0 +1,260 @@
+// Package faults injects latency, errors and timeouts into incoming requests
+// and outgoing upstream calls, for observability demos and resilience tests.
+// Rules are replaced at runtime through an admin endpoint, and every injected
+// fault is recorded as a span event.
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Latency distributions.
+const (
+	// Uniform waits Latency plus a uniform share of Jitter.
+	Uniform = "uniform"
+	// Normal waits a normally distributed time with mean Latency and standard
+	// deviation Jitter.
+	Normal = "normal"
+	// Exponential waits an exponentially distributed time with mean Latency.
+	Exponential = "exponential"
+)
+
+// Duration is a time.Duration written as "250ms" or "2s" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"250ms\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Rule describes the faults injected into the requests of one route or the
+// calls to one upstream. Exactly one of Route and Upstream is set; "*"
+// matches every route or every upstream.
+type Rule struct {
+	// Route is a route template such as /temperature/{cep}.
+	Route string `json:"route,omitempty"`
+	// Upstream is the host name of an upstream, such as viacep.com.br.
+	Upstream string `json:"upstream,omitempty"`
+
+	// Latency delays every matching request, following Distribution
+	// (uniform by default) with Jitter as its spread.
+	Latency      Duration `json:"latency,omitempty"`
+	Jitter       Duration `json:"jitter,omitempty"`
+	Distribution string   `json:"distribution,omitempty"`
+
+	// ErrorRate is the share of requests answered with Status. For upstreams,
+	// Status 0 fails the call with a connection error instead; for routes it
+	// defaults to 500.
+	ErrorRate float64 `json:"error_rate,omitempty"`
+	Status    int     `json:"status,omitempty"`
+
+	// TimeoutRate is the share of requests that hang for Timeout, or until
+	// the caller gives up, and then fail as timed out.
+	TimeoutRate float64  `json:"timeout_rate,omitempty"`
+	Timeout     Duration `json:"timeout,omitempty"`
+}
+
+func (r Rule) validate() error {
+	if (r.Route == "") == (r.Upstream == "") {
+		return fmt.Errorf("exactly one of route and upstream must be set")
+	}
+	if r.Route != "" && r.Route != "*" && !strings.HasPrefix(r.Route, "/") {
+		return fmt.Errorf("route %q must start with /", r.Route)
+	}
+	switch r.Distribution {
+	case "", Uniform, Normal, Exponential:
+	default:
+		return fmt.Errorf("unknown latency distribution %q", r.Distribution)
+	}
+	if r.Latency < 0 || r.Jitter < 0 || r.Timeout < 0 {
+		return fmt.Errorf("durations must not be negative")
+	}
+	if r.ErrorRate < 0 || r.TimeoutRate < 0 || r.ErrorRate+r.TimeoutRate > 1 {
+		return fmt.Errorf("error_rate and timeout_rate must be between 0 and 1 and add up to at most 1")
+	}
+	if r.Status != 0 && (r.Status < 100 || r.Status > 599) {
+		return fmt.Errorf("invalid status %d", r.Status)
+	}
+	if r.TimeoutRate > 0 && r.Timeout == 0 {
+		return fmt.Errorf("timeout_rate needs a timeout")
+	}
+	return nil
+}
+
+// ParseRules decodes a JSON array of rules, as accepted by the admin
+// endpoint and the FAULTS_RULES setting.
+func ParseRules(data []byte) ([]Rule, error) {
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid fault rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Injector holds the active rules. The first rule matching a request wins.
+type Injector struct {
+	mu    sync.RWMutex
+	rules []Rule
+
+	// random returns a number in [0, 1); tests replace it.
+	random func() float64
+}
+
+// New builds an injector without rules, which injects nothing.
+func New() *Injector {
+	return &Injector{random: rand.Float64}
+}
+
+// Rules returns a copy of the active rules.
+func (in *Injector) Rules() []Rule {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	return append([]Rule{}, in.rules...)
+}
+
+// SetRules validates and replaces every rule. An empty list turns injection
+// off.
+func (in *Injector) SetRules(rules []Rule) error {
+	for i, r := range rules {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	in.mu.Lock()
+	in.rules = append([]Rule(nil), rules...)
+	in.mu.Unlock()
+	return nil
+}
+
+func (in *Injector) matchRoute(path string) (Rule, bool) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	for _, r := range in.rules {
+		if r.Route == "*" || (r.Route != "" && matchTemplate(r.Route, path)) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (in *Injector) matchUpstream(host string) (Rule, bool) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	for _, r := range in.rules {
+		if r.Upstream == "*" || (r.Upstream != "" && r.Upstream == host) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// matchTemplate reports whether path fits a route template, where every
+// {param} segment matches any single segment.
+func matchTemplate(template, path string) bool {
+	want := strings.Split(strings.Trim(template, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i, segment := range want {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			continue
+		}
+		if segment != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// outcome is what the injector decided to do with one request.
+type outcome int
+
+const (
+	pass outcome = iota
+	fail
+	hang
+)
+
+// apply waits the injected latency and decides whether the request fails or
+// hangs, recording each fault on the span in ctx. It returns ctx.Err() when
+// the caller gives up while waiting.
+func (in *Injector) apply(ctx context.Context, r Rule, target string) (outcome, error) {
+	span := trace.SpanFromContext(ctx)
+
+	if delay := in.delay(r); delay > 0 {
+		span.AddEvent("fault.injected", trace.WithAttributes(
+			attribute.String("fault.kind", "latency"),
+			attribute.String("fault.target", target),
+			attribute.Int64("fault.delay_ms", delay.Milliseconds()),
+		))
+		if err := wait(ctx, delay); err != nil {
+			return pass, err
+		}
+	}
+
+	switch p := in.random(); {
+	case p < r.TimeoutRate:
+		span.AddEvent("fault.injected", trace.WithAttributes(
+			attribute.String("fault.kind", "timeout"),
+			attribute.String("fault.target", target),
+			attribute.Int64("fault.delay_ms", time.Duration(r.Timeout).Milliseconds()),
+		))
+		wait(ctx, time.Duration(r.Timeout))
+		return hang, nil
+	case p < r.TimeoutRate+r.ErrorRate:
+		span.AddEvent("fault.injected", trace.WithAttributes(
+			attribute.String("fault.kind", "error"),
+			attribute.String("fault.target", target),
+			attribute.Int("fault.status", r.Status),
+		))
+		return fail, nil
+	}
+	return pass, nil
+}
+
+func (in *Injector) delay(r Rule) time.Duration {
+	latency, jitter := float64(r.Latency), float64(r.Jitter)
+	var d float64
+	switch r.Distribution {
+	case Normal:
+		d = latency + rand.NormFloat64()*jitter
+	case Exponential:
+		d = rand.ExpFloat64() * latency
+	default:
+		d = latency + in.random()*jitter
+	}
+	return time.Duration(max(d, 0))
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
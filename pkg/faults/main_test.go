@@ -0,0 +1,95 @@
+package faults
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRulesReadsDurations(t *testing.T) {
+	rules, err := ParseRules([]byte(`[{"upstream":"viacep.com.br","latency":"250ms","jitter":"1s","timeout_rate":0.1,"timeout":"3s"}]`))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if len(rules) != 1 {
+		t.Fatalf("Expected 1 rule, but got %d", len(rules))
+	}
+	r := rules[0]
+	if time.Duration(r.Latency) != 250*time.Millisecond || time.Duration(r.Jitter) != time.Second || time.Duration(r.Timeout) != 3*time.Second {
+		t.Errorf("Expected latency 250ms, jitter 1s and timeout 3s, but got %+v", r)
+	}
+
+	if _, err := ParseRules([]byte(`[{"route":"*","latency":250}]`)); err == nil {
+		t.Errorf("Expected an error for a numeric latency, but got nil")
+	}
+}
+
+func TestSetRulesRejectsInvalidRules(t *testing.T) {
+	tests := map[string]Rule{
+		"no target":            {ErrorRate: 0.5},
+		"both targets":         {Route: "*", Upstream: "*"},
+		"relative route":       {Route: "temperature"},
+		"unknown distribution": {Route: "*", Distribution: "pareto"},
+		"rates above one":      {Route: "*", ErrorRate: 0.6, TimeoutRate: 0.6, Timeout: Duration(time.Second)},
+		"invalid status":       {Route: "*", ErrorRate: 1, Status: 42},
+		"timeout rate alone":   {Route: "*", TimeoutRate: 0.5},
+	}
+	for name, rule := range tests {
+		in := New()
+		if err := in.SetRules([]Rule{rule}); err == nil {
+			t.Errorf("Expected an error for %s, but got nil", name)
+		}
+		if len(in.Rules()) != 0 {
+			t.Errorf("Expected %s to leave the rules untouched, but got %v", name, in.Rules())
+		}
+	}
+}
+
+func TestMatchTemplate(t *testing.T) {
+	tests := []struct {
+		template, path string
+		want           bool
+	}{
+		{"/temperature/{cep}", "/temperature/01001000", true},
+		{"/temperature/{cep}", "/temperature", false},
+		{"/temperature", "/temperature", true},
+		{"/temperature", "/temperature/", true},
+		{"/temperature", "/temperatures", false},
+	}
+	for _, tt := range tests {
+		if got := matchTemplate(tt.template, tt.path); got != tt.want {
+			t.Errorf("Expected matchTemplate(%s, %s) to be %v, but got %v", tt.template, tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestFirstMatchingRuleWins(t *testing.T) {
+	in := New()
+	in.SetRules([]Rule{
+		{Upstream: "viacep.com.br", Status: 503, ErrorRate: 1},
+		{Upstream: "*", Status: 500, ErrorRate: 1},
+	})
+
+	if r, _ := in.matchUpstream("viacep.com.br"); r.Status != 503 {
+		t.Errorf("Expected the viacep rule, but got %+v", r)
+	}
+	if r, _ := in.matchUpstream("api.weatherapi.com"); r.Status != 500 {
+		t.Errorf("Expected the catch-all rule, but got %+v", r)
+	}
+	if _, ok := in.matchRoute("/temperature"); ok {
+		t.Errorf("Expected upstream rules not to match routes")
+	}
+}
+
+func TestDelayFollowsDistribution(t *testing.T) {
+	in := New()
+	in.random = func() float64 { return 0.5 }
+
+	if d := in.delay(Rule{Latency: Duration(100 * time.Millisecond), Jitter: Duration(50 * time.Millisecond)}); d != 125*time.Millisecond {
+		t.Errorf("Expected a uniform delay of 125ms, but got %s", d)
+	}
+	for range 100 {
+		if d := in.delay(Rule{Distribution: Normal, Latency: Duration(time.Millisecond), Jitter: Duration(time.Second)}); d < 0 {
+			t.Fatalf("Expected the normal delay never to be negative, but got %s", d)
+		}
+	}
+}
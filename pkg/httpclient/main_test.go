@@ -0,0 +1,114 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewVerifiesCertificatesByDefault(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	client, err := New(Options{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Errorf("Expected a self-signed certificate to be rejected, but got no error")
+	}
+}
+
+func TestNewTrustsCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, bundle, 0o600); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	client, err := New(Options{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected the CA bundle to be trusted, but got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewRejectsInvalidCABundle(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, []byte("not a certificate"), 0o600)
+
+	if _, err := New(Options{CAFile: caFile}); err == nil {
+		t.Errorf("Expected an error for an invalid CA bundle, but got nil")
+	}
+	if _, err := New(Options{CAFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Errorf("Expected an error for a missing CA bundle, but got nil")
+	}
+}
+
+func TestNewInsecureSkipsVerification(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	client, err := New(Options{Insecure: true})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected the insecure client to skip verification, but got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewAppliesWrappersAndPoolOptions(t *testing.T) {
+	var order []string
+	wrapper := func(name string) Wrapper {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(r)
+			})
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	client, err := New(Options{MaxConnsPerHost: 4}, wrapper("inner"), wrapper("outer"))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+		t.Errorf("Expected the last wrapper to run first, but got %v", order)
+	}
+
+	transport, _ := NewTransport(Options{MaxConnsPerHost: 4})
+	if transport.MaxConnsPerHost != 4 {
+		t.Errorf("Expected MaxConnsPerHost 4, but got %d", transport.MaxConnsPerHost)
+	}
+	if transport == http.DefaultTransport {
+		t.Errorf("Expected a transport of its own, but got http.DefaultTransport")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
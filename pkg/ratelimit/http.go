@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// remainingHeaders are the headers upstreams use to report their quota,
+// standard first.
+var remainingHeaders = []string{"RateLimit-Remaining", "X-RateLimit-Remaining"}
+
+// NewTransport wraps base (http.DefaultTransport when nil) for the calls to
+// upstream. When bucket is not nil, every call waits for one of its tokens.
+// The quota the upstream reports in RateLimit-Remaining or
+// X-RateLimit-Remaining is recorded on the span of the caller, and both the
+// tokens left and that quota are reported as gauges of the meter meterName
+// until Close is called. Wrap it around telemetry.NewTransport so the wait is
+// not counted in the client span.
+func NewTransport(meterName, upstream string, bucket *Bucket, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &Transport{upstream: upstream, bucket: bucket, base: base}
+	t.remaining.Store(-1)
+
+	attrs := metric.WithAttributes(attribute.String("upstream", upstream))
+	meter := otel.Meter(meterName)
+	tokens, _ := meter.Int64ObservableGauge("upstream.rate_limit.tokens",
+		metric.WithDescription("Tokens left in the client-side rate limiter of each upstream."),
+	)
+	remaining, _ := meter.Int64ObservableGauge("upstream.quota.remaining",
+		metric.WithDescription("Calls left in the quota of each upstream, as last reported by the upstream."),
+	)
+	t.registration, _ = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		if bucket != nil {
+			o.ObserveInt64(tokens, int64(bucket.Tokens()), attrs)
+		}
+		if v := t.remaining.Load(); v >= 0 {
+			o.ObserveInt64(remaining, v, attrs)
+		}
+		return nil
+	}, tokens, remaining)
+	return t
+}
+
+// Transport is the http.RoundTripper built by NewTransport.
+type Transport struct {
+	upstream     string
+	bucket       *Bucket
+	base         http.RoundTripper
+	remaining    atomic.Int64
+	registration metric.Registration
+}
+
+// Close stops reporting the gauges of the transport, so a transport that is
+// no longer used does not keep reporting stale values.
+func (t *Transport) Close() error {
+	if t.registration == nil {
+		return nil
+	}
+	return t.registration.Unregister()
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	span := trace.SpanFromContext(req.Context())
+
+	if t.bucket != nil {
+		waited, err := t.bucket.Wait(req.Context())
+		if waited > 0 {
+			span.AddEvent("rate_limit.wait", trace.WithAttributes(
+				attribute.String("upstream", t.upstream),
+				attribute.Int64("rate_limit.wait_ms", waited.Milliseconds()),
+			))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range remainingHeaders {
+		if v, err := strconv.ParseInt(resp.Header.Get(h), 10, 64); err == nil {
+			t.remaining.Store(v)
+			span.SetAttributes(attribute.Int64("upstream.quota.remaining", v))
+			break
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTransportRecordsRemainingQuota(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "41")
+	}))
+	t.Cleanup(srv.Close)
+
+	sr := tracetest.NewSpanRecorder()
+	ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer("test").Start(context.Background(), "TestSpan")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	rt := NewTransport("test", "upstream", nil, nil)
+	t.Cleanup(func() { rt.Close() })
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	span.End()
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+
+	if got := rt.remaining.Load(); got != 41 {
+		t.Errorf("Expected remaining quota 41, but got %d", got)
+	}
+	found := false
+	for _, kv := range sr.Ended()[0].Attributes() {
+		if kv.Key == "upstream.quota.remaining" && kv.Value.AsInt64() == 41 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the span to carry upstream.quota.remaining=41, but it did not")
+	}
+}
+
+func TestTransportHoldsCallsBackWithoutTokens(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	t.Cleanup(srv.Close)
+
+	rt := NewTransport("test", "upstream", NewBucket(0.1, 1), nil)
+	t.Cleanup(func() { rt.Close() })
+	client := &http.Client{Transport: rt}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected the first call to go through, but got %v", err)
+	}
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if _, err := client.Do(req); !errors.Is(err, ErrLimited) {
+		t.Errorf("Expected ErrLimited, but got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call to reach the upstream, but got %d", calls)
+	}
+}
+
+func TestTransportStopsReportingWhenClosed(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	gauges := func() int {
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(context.Background(), &rm); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		n := 0
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if gauge, ok := m.Data.(metricdata.Gauge[int64]); ok {
+					n += len(gauge.DataPoints)
+				}
+			}
+		}
+		return n
+	}
+
+	rt := NewTransport("test", "upstream", NewBucket(1, 1), nil)
+	if n := gauges(); n != 1 {
+		t.Fatalf("Expected the tokens gauge to be reported, but got %d data points", n)
+	}
+	if err := rt.Close(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if n := gauges(); n != 0 {
+		t.Errorf("Expected a closed transport to stop reporting, but got %d data points", n)
+	}
+}
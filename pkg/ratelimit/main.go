@@ -0,0 +1,123 @@
+// Package ratelimit provides the token buckets the services use to pace their
+// calls to upstreams with a quota, to track how much of that quota the
+// upstreams say is left, and to limit the requests their own clients send.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+)
+
+// ErrLimited is returned when a call would have to wait for a token longer
+// than its deadline allows.
+var ErrLimited = telemetry.WithCategory(errors.New("rate limit exceeded"), "rate_limited")
+
+// Bucket is a token bucket that refills rate tokens every second and holds at
+// most burst of them. It is safe for concurrent use.
+type Bucket struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket builds a full bucket. A burst below 1 holds a single token.
+func NewBucket(rate float64, burst int) *Bucket {
+	b := &Bucket{rate: rate, burst: math.Max(float64(burst), 1), now: time.Now}
+	b.tokens = b.burst
+	b.last = b.now()
+	return b
+}
+
+// Burst returns the most tokens the bucket holds.
+func (b *Bucket) Burst() int {
+	return int(b.burst)
+}
+
+// Tokens returns how many whole tokens are available right now.
+func (b *Bucket) Tokens() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	return max(int(b.tokens), 0)
+}
+
+// Take takes a token if one is available. Otherwise it returns how long until
+// the next one is.
+func (b *Bucket) Take() (ok bool, wait time.Duration) {
+	return b.TakeN(1)
+}
+
+// TakeN takes n tokens at once. A cost above the burst is let through once
+// the bucket is full, leaving it in debt for the calls that follow, so it is
+// possible but never cheaper. Otherwise it returns how long until enough
+// tokens are available.
+func (b *Bucket) TakeN(n int) (ok bool, wait time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	need := math.Min(float64(n), b.burst)
+	if b.tokens >= need {
+		b.tokens -= float64(n)
+		return true, 0
+	}
+	return false, time.Duration(math.Ceil((need - b.tokens) / b.rate * float64(time.Second)))
+}
+
+// Put gives back n tokens taken for a call that did not happen, up to the
+// burst.
+func (b *Bucket) Put(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.tokens = math.Min(b.burst, b.tokens+float64(n))
+}
+
+// Reset returns how long until the bucket is full again.
+func (b *Bucket) Reset() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	return time.Duration((b.burst - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Wait takes a token, waiting for one as long as ctx allows, and returns how
+// long it waited. It fails with ErrLimited right away when the next token
+// comes after the deadline of ctx.
+func (b *Bucket) Wait(ctx context.Context) (time.Duration, error) {
+	var waited time.Duration
+	for {
+		ok, wait := b.Take()
+		if ok {
+			return waited, nil
+		}
+		if d, has := ctx.Deadline(); has && time.Until(d) < wait {
+			return waited, ErrLimited
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+			waited += wait
+		case <-ctx.Done():
+			timer.Stop()
+			return waited, ctx.Err()
+		}
+	}
+}
+
+// refill adds the tokens earned since the last call. It must be called with
+// mu held.
+func (b *Bucket) refill() {
+	now := b.now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock lets tests move the time a bucket sees.
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestBucket(rate float64, burst int) (*Bucket, *fakeClock) {
+	clock := &fakeClock{now: time.Now()}
+	b := NewBucket(rate, burst)
+	b.now = clock.Now
+	b.last = clock.now
+	return b, clock
+}
+
+func TestBucketAllowsBurstThenRefills(t *testing.T) {
+	b, clock := newTestBucket(2, 3)
+
+	for i := range 3 {
+		if ok, _ := b.Take(); !ok {
+			t.Fatalf("Expected token %d of the burst, but got none", i+1)
+		}
+	}
+	ok, wait := b.Take()
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("Expected to wait 500ms for the next token, but got ok=%v wait=%s", ok, wait)
+	}
+
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	if ok, _ := b.Take(); !ok {
+		t.Errorf("Expected a token after 500ms, but got none")
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	if b.Tokens() != 3 {
+		t.Errorf("Expected the bucket to refill up to its burst of 3, but got %d", b.Tokens())
+	}
+}
+
+func TestBucketPutGivesTokensBackUpToBurst(t *testing.T) {
+	b, _ := newTestBucket(1, 2)
+
+	b.Take()
+	b.Take()
+	b.Put(1)
+	if b.Tokens() != 1 {
+		t.Errorf("Expected 1 token back, but got %d", b.Tokens())
+	}
+	b.Put(5)
+	if b.Tokens() != 2 {
+		t.Errorf("Expected the bucket to stop at its burst of 2, but got %d", b.Tokens())
+	}
+}
+
+func TestBucketTakeNGoesIntoDebtPastBurst(t *testing.T) {
+	b, clock := newTestBucket(1, 2)
+
+	if ok, _ := b.TakeN(5); !ok {
+		t.Fatalf("Expected a full bucket to let a cost above its burst through")
+	}
+	if b.Tokens() != 0 {
+		t.Errorf("Expected no tokens left, but got %d", b.Tokens())
+	}
+	ok, wait := b.Take()
+	if ok || wait != 4*time.Second {
+		t.Errorf("Expected to wait 4s to pay off the debt, but got ok=%v wait=%s", ok, wait)
+	}
+
+	clock.now = clock.now.Add(4 * time.Second)
+	if ok, _ := b.Take(); !ok {
+		t.Errorf("Expected a token once the debt is paid, but got none")
+	}
+}
+
+func TestBucketWaitsForToken(t *testing.T) {
+	b := NewBucket(20, 1)
+	b.Take()
+
+	waited, err := b.Wait(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if waited <= 0 {
+		t.Errorf("Expected to wait for a token, but got %s", waited)
+	}
+}
+
+func TestBucketFailsFastPastDeadline(t *testing.T) {
+	b := NewBucket(0.1, 1)
+	b.Take()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := b.Wait(ctx); !errors.Is(err, ErrLimited) {
+		t.Errorf("Expected ErrLimited, but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Expected to fail at once, but waited %s", elapsed)
+	}
+}
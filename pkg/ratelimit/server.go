@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"container/list"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/EnnioSimoes/2-Observabilidade/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxClients bounds the buckets kept in memory. Past it, the bucket of the
+// client seen least recently is dropped.
+const maxClients = 10000
+
+// Limit is a sustained rate, in requests per second, and the burst allowed on
+// top of it. A Rate of 0 or less means no limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) bucket() *Bucket {
+	if l.Rate <= 0 {
+		return nil
+	}
+	return NewBucket(l.Rate, l.Burst)
+}
+
+// Limiter limits the requests each client sends, with a ceiling shared by all
+// of them. It is safe for concurrent use.
+type Limiter struct {
+	perClient Limit
+	global    *Bucket
+	rejected  metric.Int64Counter
+
+	mu         sync.Mutex
+	maxClients int
+	clients    map[string]*list.Element
+	// recent orders the clients from the most to the least recently seen.
+	recent *list.List
+}
+
+type clientBucket struct {
+	key    string
+	bucket *Bucket
+}
+
+// NewLimiter builds a limiter and counts the requests it rejects in the
+// http.server.rate_limited counter of the meter meterName.
+func NewLimiter(meterName string, perClient, global Limit) *Limiter {
+	l := &Limiter{
+		perClient:  perClient,
+		global:     global.bucket(),
+		maxClients: maxClients,
+		clients:    map[string]*list.Element{},
+		recent:     list.New(),
+	}
+	l.rejected, _ = otel.Meter(meterName).Int64Counter("http.server.rate_limited",
+		metric.WithDescription("Number of HTTP requests rejected by the rate limiter, by scope."),
+	)
+	return l
+}
+
+// client returns the bucket of the client key, creating it on first use.
+func (l *Limiter) client(key string) *Bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.clients[key]; ok {
+		l.recent.MoveToFront(e)
+		return e.Value.(*clientBucket).bucket
+	}
+	if l.recent.Len() >= l.maxClients {
+		oldest := l.recent.Back()
+		l.recent.Remove(oldest)
+		delete(l.clients, oldest.Value.(*clientBucket).key)
+	}
+	b := l.perClient.bucket()
+	l.clients[key] = l.recent.PushFront(&clientBucket{key: key, bucket: b})
+	return b
+}
+
+// Middleware rejects with 429 the requests over the limit of their client,
+// identified by clientKey, or over the global ceiling, as Allow does for a
+// cost of one token.
+func (l *Limiter) Middleware(clientKey func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if l.Allow(w, r, clientKey(r), 1) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// Allow takes n tokens from the bucket of the client key and from the global
+// ceiling, for a request that costs n calls, and reports whether the request
+// may go on. Otherwise it has already answered 429 with a Retry-After header.
+// Either way w carries the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers of the client's bucket. It must run inside the
+// telemetry server middleware so rejections land on the server span.
+func (l *Limiter) Allow(w http.ResponseWriter, r *http.Request, key string, n int) bool {
+	var bucket *Bucket
+	if l.perClient.Rate > 0 {
+		bucket = l.client(key)
+	}
+
+	scope := ""
+	var wait time.Duration
+	if ok, d := take(bucket, n); !ok {
+		scope, wait = "client", d
+	} else if ok, d := take(l.global, n); !ok {
+		// A request the ceiling turns away must not cost its client anything
+		scope, wait = "global", d
+		if bucket != nil {
+			bucket.Put(n)
+		}
+	}
+
+	if bucket != nil {
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(bucket.Burst()))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(bucket.Tokens()))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(bucket.Reset())))
+	}
+	if scope == "" {
+		return true
+	}
+
+	ctx := r.Context()
+	attrs := []attribute.KeyValue{
+		attribute.String("rate_limit.scope", scope),
+		attribute.Int64("rate_limit.retry_after_ms", wait.Milliseconds()),
+		attribute.Int("rate_limit.cost", n),
+	}
+	slog.WarnContext(ctx, "Request rate limited", "scope", scope, "cost", n, "retry_after", wait)
+	telemetry.RecordError(trace.SpanFromContext(ctx), ErrLimited, attrs...)
+	l.rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("rate_limit.scope", scope)))
+
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds(wait), 1)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]string{"error": "rate limit exceeded", "trace_id": telemetry.TraceID(ctx)})
+	return false
+}
+
+// take takes n tokens from b, which lets everything through when nil.
+func take(b *Bucket, n int) (bool, time.Duration) {
+	if b == nil {
+		return true, 0
+	}
+	return b.TakeN(n)
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveLimited(l *Limiter, client string) *httptest.ResponseRecorder {
+	h := l.Middleware(func(r *http.Request) string { return r.Header.Get("X-Client") })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+	req := httptest.NewRequest(http.MethodPost, "/temperature", nil)
+	req.Header.Set("X-Client", client)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddlewareLimitsEachClient(t *testing.T) {
+	l := NewLimiter("test", Limit{Rate: 0.1, Burst: 2}, Limit{})
+
+	for i, want := range []string{"1", "0"} {
+		rec := serveLimited(l, "a")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected request %d to pass, but got %d", i+1, rec.Code)
+		}
+		if rec.Header().Get("RateLimit-Limit") != "2" || rec.Header().Get("RateLimit-Remaining") != want {
+			t.Errorf("Expected RateLimit-Limit 2 and RateLimit-Remaining %s, but got %s and %s", want, rec.Header().Get("RateLimit-Limit"), rec.Header().Get("RateLimit-Remaining"))
+		}
+	}
+
+	rec := serveLimited(l, "a")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 past the burst, but got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "10" {
+		t.Errorf("Expected Retry-After 10, but got %q", rec.Header().Get("Retry-After"))
+	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body["error"] != "rate limit exceeded" {
+		t.Errorf("Expected a JSON error body, but got %v (%v)", body, err)
+	}
+
+	if rec := serveLimited(l, "b"); rec.Code != http.StatusOK {
+		t.Errorf("Expected another client to have its own limit, but got %d", rec.Code)
+	}
+}
+
+func TestMiddlewareEnforcesGlobalCeiling(t *testing.T) {
+	l := NewLimiter("test", Limit{Rate: 10, Burst: 10}, Limit{Rate: 0.1, Burst: 1})
+
+	if rec := serveLimited(l, "a"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the first request to pass, but got %d", rec.Code)
+	}
+	if rec := serveLimited(l, "b"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 past the global ceiling, but got %d", rec.Code)
+	}
+}
+
+func TestMiddlewareRefundsClientRejectedByGlobalCeiling(t *testing.T) {
+	l := NewLimiter("test", Limit{Rate: 0.1, Burst: 1}, Limit{Rate: 0.1, Burst: 1})
+
+	if rec := serveLimited(l, "a"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the first request to pass, but got %d", rec.Code)
+	}
+	rec := serveLimited(l, "b")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 past the global ceiling, but got %d", rec.Code)
+	}
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "1" {
+		t.Errorf("Expected the client to keep its token, but got RateLimit-Remaining %s", got)
+	}
+}
+
+func TestLimiterEvictsLeastRecentlySeenClient(t *testing.T) {
+	l := NewLimiter("test", Limit{Rate: 0.001, Burst: 1}, Limit{})
+	l.maxClients = 2
+
+	for _, client := range []string{"a", "b", "a", "c"} {
+		serveLimited(l, client)
+	}
+	if len(l.clients) != 2 {
+		t.Errorf("Expected 2 clients to be kept, but got %d", len(l.clients))
+	}
+	if rec := serveLimited(l, "a"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the recently seen client to keep its bucket, but got %d", rec.Code)
+	}
+	if rec := serveLimited(l, "b"); rec.Code != http.StatusOK {
+		t.Errorf("Expected the least recently seen client to start over, but got %d", rec.Code)
+	}
+}
+
+func TestAllowChargesEveryToken(t *testing.T) {
+	l := NewLimiter("test", Limit{Rate: 0.001, Burst: 5}, Limit{})
+	allow := func(n int) (bool, *httptest.ResponseRecorder) {
+		rec := httptest.NewRecorder()
+		return l.Allow(rec, httptest.NewRequest(http.MethodPost, "/temperature/batch", nil), "a", n), rec
+	}
+
+	ok, rec := allow(3)
+	if !ok || rec.Header().Get("RateLimit-Remaining") != "2" {
+		t.Fatalf("Expected a cost of 3 to leave 2 tokens, but got ok=%v remaining=%s", ok, rec.Header().Get("RateLimit-Remaining"))
+	}
+	ok, rec = allow(3)
+	if ok || rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 for a cost above the tokens left, but got ok=%v status=%d", ok, rec.Code)
+	}
+	if ok, _ := allow(2); !ok {
+		t.Errorf("Expected the rejected request not to spend the tokens left")
+	}
+}
+
+func TestMiddlewareWithoutLimits(t *testing.T) {
+	l := NewLimiter("test", Limit{}, Limit{})
+
+	for range 100 {
+		if rec := serveLimited(l, "a"); rec.Code != http.StatusOK {
+			t.Fatalf("Expected no limit, but got %d", rec.Code)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package retry resends upstream calls that failed for transient reasons,
+// with exponential backoff and jitter, and records every retry as an event on
+// the span of the caller.
+package retry
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Policy decides which calls are resent and how long to wait in between.
+type Policy struct {
+	// MaxAttempts counts the first try; 1 or less disables retries.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubled on every retry up
+	// to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter is the share of every wait that is randomized, from 0 (none) to
+	// 1 (anywhere between zero and the full wait), so callers that failed
+	// together do not retry together.
+	Jitter float64
+	// StatusCodes are the responses worth retrying. Network errors always
+	// are, unless the caller gave up.
+	StatusCodes []int
+}
+
+// backoff returns the wait before the given retry, counting from 1.
+func (p Policy) backoff(retry int, random func() float64) time.Duration {
+	d := p.BaseDelay << min(retry-1, 30)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	return d - time.Duration(p.Jitter*random()*float64(d))
+}
+
+// NewTransport wraps base (http.DefaultTransport when nil) so calls failing
+// with a network error or one of the policy's status codes are resent. Only
+// idempotent requests are retried: GET, HEAD, OPTIONS, TRACE, PUT and DELETE,
+// or any request carrying an Idempotency-Key header, as long as its body can
+// be replayed. Wrap it around telemetry.NewTransport so every attempt gets its
+// own client span.
+func NewTransport(policy Policy, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{policy: policy, base: base, random: rand.Float64}
+}
+
+type transport struct {
+	policy Policy
+	base   http.RoundTripper
+	random func() float64
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.policy.MaxAttempts <= 1 || !replayable(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if attempt == t.policy.MaxAttempts || ctx.Err() != nil {
+			return resp, err
+		}
+
+		var reason string
+		var delay time.Duration
+		switch {
+		case err != nil:
+			reason = err.Error()
+			delay = t.policy.backoff(attempt, t.random)
+		case slices.Contains(t.policy.StatusCodes, resp.StatusCode):
+			reason = resp.Status
+			delay = t.policy.backoff(attempt, t.random)
+			if after, ok := retryAfter(resp); ok {
+				// A wait longer than the policy allows ends the retries.
+				if t.policy.MaxDelay > 0 && after > t.policy.MaxDelay {
+					return resp, nil
+				}
+				delay = max(delay, after)
+			}
+		default:
+			return resp, nil
+		}
+
+		// Waiting past the deadline would only turn the answer into a timeout.
+		if d, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(d) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("retry.attempt", attempt+1),
+			attribute.String("retry.reason", reason),
+			attribute.Int64("retry.delay_ms", delay.Milliseconds()),
+			attribute.String("server.address", req.URL.Hostname()),
+		))
+		if err := wait(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func replayable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if req.Header.Get("Idempotency-Key") != "" {
+		return true
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryAfter reads the Retry-After header, in seconds or as an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,180 @@
+package retry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var testPolicy = Policy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    10 * time.Millisecond,
+	StatusCodes: []int{http.StatusServiceUnavailable},
+}
+
+// flakyServer answers the first failures requests with status, and 200 after
+// that. It returns the number of requests it received.
+func flakyServer(t *testing.T, failures int32, status int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if n := calls.Add(1); n <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestTransportRetriesRetryableStatus(t *testing.T) {
+	srv, calls := flakyServer(t, 2, http.StatusServiceUnavailable, nil)
+
+	sr := tracetest.NewSpanRecorder()
+	ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer("test").Start(context.Background(), "TestSpan")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := (&http.Client{Transport: NewTransport(testPolicy, nil)}).Do(req)
+	span.End()
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Errorf("Expected a 200 on the third attempt, but got %d after %d attempts", resp.StatusCode, calls.Load())
+	}
+
+	events := sr.Ended()[0].Events()
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 retry events, but got %d", len(events))
+	}
+	for i, e := range events {
+		for _, kv := range e.Attributes {
+			if kv.Key == "retry.attempt" && kv.Value.AsInt64() != int64(i+2) {
+				t.Errorf("Expected retry event %d to be attempt %d, but got %d", i, i+2, kv.Value.AsInt64())
+			}
+		}
+	}
+}
+
+func TestTransportGivesUpAfterMaxAttempts(t *testing.T) {
+	srv, calls := flakyServer(t, 10, http.StatusServiceUnavailable, nil)
+
+	resp, err := (&http.Client{Transport: NewTransport(testPolicy, nil)}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 3 {
+		t.Errorf("Expected the last 503 after 3 attempts, but got %d after %d attempts", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestTransportSkipsNonRetryableRequests(t *testing.T) {
+	tests := map[string]struct {
+		status int
+		method string
+		body   string
+	}{
+		"client error": {http.StatusNotFound, http.MethodGet, ""},
+		"POST":         {http.StatusServiceUnavailable, http.MethodPost, "{}"},
+	}
+	for name, tt := range tests {
+		srv, calls := flakyServer(t, 1, tt.status, nil)
+		req, _ := http.NewRequest(tt.method, srv.URL, strings.NewReader(tt.body))
+		resp, err := (&http.Client{Transport: NewTransport(testPolicy, nil)}).Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		resp.Body.Close()
+		if calls.Load() != 1 {
+			t.Errorf("Expected %s not to be retried, but got %d attempts", name, calls.Load())
+		}
+	}
+}
+
+func TestTransportReplaysBodyOfIdempotentRequests(t *testing.T) {
+	srv, calls := flakyServer(t, 1, http.StatusServiceUnavailable, nil)
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"cep":"01001000"}`))
+	req.Header.Set("Idempotency-Key", "abc")
+	resp, err := (&http.Client{Transport: NewTransport(testPolicy, nil)}).Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if calls.Load() != 2 || string(body) != `{"cep":"01001000"}` {
+		t.Errorf("Expected the body to be resent on the second attempt, but got %q after %d attempts", body, calls.Load())
+	}
+}
+
+func TestTransportHonorsRetryAfter(t *testing.T) {
+	srv, calls := flakyServer(t, 1, http.StatusServiceUnavailable, http.Header{"Retry-After": {"1"}})
+
+	policy := testPolicy
+	policy.MaxDelay = 2 * time.Second
+	start := time.Now()
+	resp, err := (&http.Client{Transport: NewTransport(policy, nil)}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); calls.Load() != 2 || elapsed < time.Second {
+		t.Errorf("Expected a retry after 1s, but got %d attempts in %s", calls.Load(), elapsed)
+	}
+
+	// A Retry-After longer than MaxDelay ends the retries.
+	srv, calls = flakyServer(t, 1, http.StatusServiceUnavailable, http.Header{"Retry-After": {"60"}})
+	resp, err = (&http.Client{Transport: NewTransport(testPolicy, nil)}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 1 || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected no retry past MaxDelay, but got %d attempts", calls.Load())
+	}
+}
+
+func TestTransportStopsAtDeadline(t *testing.T) {
+	srv, calls := flakyServer(t, 10, http.StatusServiceUnavailable, nil)
+
+	policy := testPolicy
+	policy.BaseDelay = time.Second
+	policy.MaxDelay = time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := (&http.Client{Transport: NewTransport(policy, nil)}).Do(req)
+	if err != nil {
+		t.Fatalf("Expected the last answer instead of an error, but got %v", err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 1 {
+		t.Errorf("Expected no retry that would outlive the deadline, but got %d attempts", calls.Load())
+	}
+}
+
+func TestBackoffDoublesUpToMaxDelay(t *testing.T) {
+	p := Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond, Jitter: 0.5}
+	half := func() float64 { return 0.5 }
+
+	for retry, want := range map[int]time.Duration{1: 75 * time.Millisecond, 2: 150 * time.Millisecond, 3: 225 * time.Millisecond, 40: 225 * time.Millisecond} {
+		if got := p.backoff(retry, half); got != want {
+			t.Errorf("Expected retry %d to wait %s, but got %s", retry, want, got)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// BaggageAttributes returns the members of the baggage in ctx named by keys,
+// as attributes with the same names. Missing members are skipped.
+func BaggageAttributes(ctx context.Context, keys []string) []attribute.KeyValue {
+	if len(keys) == 0 {
+		return nil
+	}
+	b := baggage.FromContext(ctx)
+	var attrs []attribute.KeyValue
+	for _, key := range keys {
+		if m := b.Member(key); m.Key() != "" {
+			attrs = append(attrs, attribute.String(key, m.Value()))
+		}
+	}
+	return attrs
+}
+
+const (
+	// maxMetricBaggageValues bounds the distinct values of each baggage key
+	// on metrics; later values are reported as otherBaggageValue.
+	maxMetricBaggageValues = 100
+	// maxMetricBaggageLength is the longest baggage value kept on metrics.
+	maxMetricBaggageLength = 64
+	otherBaggageValue      = "other"
+)
+
+// metricBaggage turns baggage members into metric attributes while bounding
+// their cardinality, since the values come from clients: values longer than
+// maxMetricBaggageLength, and new values once a key has seen
+// maxMetricBaggageValues, are reported as otherBaggageValue. Spans and logs
+// keep the raw values. It is safe for concurrent use.
+type metricBaggage struct {
+	keys []string
+
+	mu   sync.Mutex
+	seen map[string]map[string]struct{}
+}
+
+func newMetricBaggage(keys []string) *metricBaggage {
+	return &metricBaggage{keys: keys, seen: make(map[string]map[string]struct{})}
+}
+
+func (b *metricBaggage) attributes(ctx context.Context) []attribute.KeyValue {
+	attrs := BaggageAttributes(ctx, b.keys)
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, kv := range attrs {
+		key, value := string(kv.Key), kv.Value.AsString()
+		seen := b.seen[key]
+		if seen == nil {
+			seen = make(map[string]struct{})
+			b.seen[key] = seen
+		}
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		if len(value) > maxMetricBaggageLength || len(seen) >= maxMetricBaggageValues {
+			attrs[i] = attribute.String(key, otherBaggageValue)
+			continue
+		}
+		seen[value] = struct{}{}
+	}
+	return attrs
+}
+
+// baggageSpanProcessor copies the configured baggage members onto every span
+// when it starts, so spans can be filtered by tenant without passing it
+// around explicitly.
+type baggageSpanProcessor struct {
+	keys []string
+}
+
+func (p baggageSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	s.SetAttributes(BaggageAttributes(parent, p.keys)...)
+}
+
+func (p baggageSpanProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+func (p baggageSpanProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (p baggageSpanProcessor) ForceFlush(context.Context) error {
+	return nil
+}
+
+// baggageHandler adds the configured baggage members to every log record.
+type baggageHandler struct {
+	slog.Handler
+	keys []string
+}
+
+func (h baggageHandler) Handle(ctx context.Context, r slog.Record) error {
+	for _, kv := range BaggageAttributes(ctx, h.keys) {
+		r.AddAttrs(slog.String(string(kv.Key), kv.Value.AsString()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h baggageHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return baggageHandler{Handler: h.Handler.WithAttrs(attrs), keys: h.keys}
+}
+
+func (h baggageHandler) WithGroup(name string) slog.Handler {
+	return baggageHandler{Handler: h.Handler.WithGroup(name), keys: h.keys}
+}
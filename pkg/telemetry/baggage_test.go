@@ -0,0 +1,98 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var testBaggageKeys = []string{"tenant.id", "client.app"}
+
+func contextWithBaggage(t *testing.T, members map[string]string) context.Context {
+	t.Helper()
+	var list []baggage.Member
+	for k, v := range members {
+		m, err := baggage.NewMemberRaw(k, v)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		list = append(list, m)
+	}
+	b, err := baggage.New(list...)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	return baggage.ContextWithBaggage(context.Background(), b)
+}
+
+func TestBaggageSpanProcessorCopiesConfiguredKeys(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(baggageSpanProcessor{keys: testBaggageKeys}),
+		sdktrace.WithSpanProcessor(sr),
+	)
+
+	ctx := contextWithBaggage(t, map[string]string{"tenant.id": "acme", "secret": "do-not-copy"})
+	ctx, parent := tp.Tracer("test").Start(ctx, "ParentSpan")
+	_, child := tp.Tracer("test").Start(ctx, "ChildSpan")
+	child.End()
+	parent.End()
+
+	for _, s := range sr.Ended() {
+		if tenant := spanAttribute(s, "tenant.id").AsString(); tenant != "acme" {
+			t.Errorf("Expected %s to have tenant.id acme, but got %q", s.Name(), tenant)
+		}
+		if secret := spanAttribute(s, "secret").AsString(); secret != "" {
+			t.Errorf("Expected %s not to copy unconfigured keys, but got secret=%q", s.Name(), secret)
+		}
+	}
+}
+
+func TestBaggageHandlerAddsConfiguredKeys(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(baggageHandler{Handler: slog.NewTextHandler(&buf, nil), keys: testBaggageKeys})
+
+	ctx := contextWithBaggage(t, map[string]string{"tenant.id": "acme", "client.app": "mobile app"})
+	logger.InfoContext(ctx, "Request completed")
+
+	for _, want := range []string{"tenant.id=acme", `client.app="mobile app"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected the log line to contain %s, but got %s", want, buf.String())
+		}
+	}
+}
+
+func TestMetricBaggageBoundsValuesPerKey(t *testing.T) {
+	b := newMetricBaggage(testBaggageKeys)
+	value := func(members map[string]string) string {
+		for _, kv := range b.attributes(contextWithBaggage(t, members)) {
+			if kv.Key == "tenant.id" {
+				return kv.Value.AsString()
+			}
+		}
+		return ""
+	}
+
+	if got := value(map[string]string{"tenant.id": strings.Repeat("a", maxMetricBaggageLength+1)}); got != otherBaggageValue {
+		t.Errorf("Expected a long value to be reported as %s, but got %s", otherBaggageValue, got)
+	}
+	for i := range maxMetricBaggageValues {
+		value(map[string]string{"tenant.id": "tenant-" + strconv.Itoa(i)})
+	}
+	if got := value(map[string]string{"tenant.id": "one-too-many"}); got != otherBaggageValue {
+		t.Errorf("Expected a value past the limit to be reported as %s, but got %s", otherBaggageValue, got)
+	}
+	if got := value(map[string]string{"tenant.id": "tenant-0"}); got != "tenant-0" {
+		t.Errorf("Expected a value seen before to be kept, but got %s", got)
+	}
+	if got := b.attributes(contextWithBaggage(t, map[string]string{"client.app": "mobile"})); len(got) != 1 || got[0].Value.AsString() != "mobile" {
+		t.Errorf("Expected every key to have its own limit, but got %v", got)
+	}
+}
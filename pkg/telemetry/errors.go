@@ -0,0 +1,93 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StatusError is returned when an upstream answers with an unexpected HTTP
+// status. RecordError reports the status as upstream.status_code.
+type StatusError struct {
+	StatusCode int
+	Host       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d from %s", e.StatusCode, e.Host)
+}
+
+func (e *StatusError) Category() string {
+	return "upstream_status"
+}
+
+// WithCategory tags err with the category RecordError writes to
+// error.category. errors.Is and errors.As still see err.
+func WithCategory(err error, category string) error {
+	return &categorizedError{err: err, category: category}
+}
+
+type categorizedError struct {
+	err      error
+	category string
+}
+
+func (e *categorizedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *categorizedError) Unwrap() error {
+	return e.err
+}
+
+func (e *categorizedError) Category() string {
+	return e.category
+}
+
+// ErrorCategory classifies err for the error.category attribute: the category
+// of the outermost categorized error in the chain, else timeout, canceled,
+// decode, network or internal.
+func ErrorCategory(err error) string {
+	var categorized interface{ Category() string }
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &categorized):
+		return categorized.Category()
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return "decode"
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	}
+	return "internal"
+}
+
+// RecordError records err as an exception event on span, marks the span as
+// failed and attaches error.category, upstream.status_code when the chain
+// holds a StatusError, and the given domain attributes.
+func RecordError(span trace.Span, err error, attrs ...attribute.KeyValue) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.SetAttributes(attribute.String("error.category", ErrorCategory(err)))
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		span.SetAttributes(attribute.Int("upstream.status_code", statusErr.StatusCode))
+	}
+	span.SetAttributes(attrs...)
+}
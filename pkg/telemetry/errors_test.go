@@ -0,0 +1,72 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestErrorCategory(t *testing.T) {
+	var decodeErr error = json.Unmarshal([]byte("{"), &struct{}{})
+
+	tests := []struct {
+		err      error
+		category string
+	}{
+		{&StatusError{StatusCode: 502, Host: "viacep.com.br"}, "upstream_status"},
+		{fmt.Errorf("viacep: %w", context.DeadlineExceeded), "timeout"},
+		{context.Canceled, "canceled"},
+		{decodeErr, "decode"},
+		{WithCategory(errors.New("invalid zipcode"), "invalid_cep"), "invalid_cep"},
+		{WithCategory(fmt.Errorf("all failed: %w", &StatusError{StatusCode: 500}), "upstream_unavailable"), "upstream_unavailable"},
+		{errors.New("boom"), "internal"},
+	}
+
+	for _, tt := range tests {
+		if category := ErrorCategory(tt.err); category != tt.category {
+			t.Errorf("Expected category %s for %q, but got %s", tt.category, tt.err, category)
+		}
+	}
+}
+
+func TestWithCategoryKeepsErrorChain(t *testing.T) {
+	err := WithCategory(context.DeadlineExceeded, "upstream_unavailable")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the categorized error to wrap context.DeadlineExceeded")
+	}
+	if err.Error() != context.DeadlineExceeded.Error() {
+		t.Errorf("Expected message %q, but got %q", context.DeadlineExceeded.Error(), err.Error())
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	sr := newSpanRecorder()
+	_, span := otel.Tracer("test").Start(context.Background(), "GetLocationByCep viacep")
+
+	err := fmt.Errorf("viacep: %w", &StatusError{StatusCode: 503, Host: "viacep.com.br"})
+	RecordError(span, err, attribute.String("address.cep", "01001000"))
+	span.End()
+
+	s := sr.Ended()[0]
+	if s.Status().Code != codes.Error {
+		t.Errorf("Expected the span status to be Error, but got %s", s.Status().Code)
+	}
+	if len(s.Events()) != 1 || s.Events()[0].Name != "exception" {
+		t.Errorf("Expected an exception event, but got %v", s.Events())
+	}
+	if category := spanAttribute(s, "error.category").AsString(); category != "upstream_status" {
+		t.Errorf("Expected error.category upstream_status, but got %s", category)
+	}
+	if status := spanAttribute(s, "upstream.status_code").AsInt64(); status != 503 {
+		t.Errorf("Expected upstream.status_code 503, but got %d", status)
+	}
+	if cep := spanAttribute(s, "address.cep").AsString(); cep != "01001000" {
+		t.Errorf("Expected address.cep 01001000, but got %s", cep)
+	}
+}
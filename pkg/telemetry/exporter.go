@@ -0,0 +1,362 @@
+package telemetry
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	ProtocolGRPC         = "grpc"
+	ProtocolHTTPProtobuf = "http/protobuf"
+)
+
+// ExporterConfig describes where and how the OTLP exporters send telemetry.
+type ExporterConfig struct {
+	// Endpoint is a URL such as http://otel-collector:4317. An http scheme
+	// disables TLS; without a scheme, Insecure decides.
+	Endpoint string
+	// SignalURL marks an Endpoint read from a per-signal variable such as
+	// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT: over HTTP it is the full URL, and
+	// /v1/traces is not appended to it.
+	SignalURL bool
+	// Protocol is either "grpc" or "http/protobuf".
+	Protocol    string
+	Headers     map[string]string
+	Compression string
+	Insecure    bool
+	Timeout     time.Duration
+
+	// PEM files used to verify the collector (CACertificate) and to
+	// authenticate against it with mTLS (ClientCertificate and ClientKey).
+	CACertificate     string
+	ClientCertificate string
+	ClientKey         string
+}
+
+// ExporterConfigFromEnv reads the standard OTEL_EXPORTER_OTLP_* variables.
+func ExporterConfigFromEnv() (ExporterConfig, error) {
+	return exporterConfigFromEnv("")
+}
+
+// SignalExporterConfigFromEnv reads the configuration of one signal, "traces",
+// "metrics" or "logs": every OTEL_EXPORTER_OTLP_<SIGNAL>_* variable that is
+// set takes precedence over its OTEL_EXPORTER_OTLP_* counterpart.
+func SignalExporterConfigFromEnv(signal string) (ExporterConfig, error) {
+	return exporterConfigFromEnv(signal)
+}
+
+func exporterConfigFromEnv(signal string) (ExporterConfig, error) {
+	// getenv returns the per-signal variable when it is set, else the
+	// generic one, along with the name of the variable it read.
+	getenv := func(name string) (string, string) {
+		if signal != "" {
+			key := "OTEL_EXPORTER_OTLP_" + strings.ToUpper(signal) + "_" + name
+			if v := os.Getenv(key); v != "" {
+				return v, key
+			}
+		}
+		key := "OTEL_EXPORTER_OTLP_" + name
+		return os.Getenv(key), key
+	}
+
+	var c ExporterConfig
+	var key string
+	c.Endpoint, key = getenv("ENDPOINT")
+	c.SignalURL = c.Endpoint != "" && key != "OTEL_EXPORTER_OTLP_ENDPOINT"
+	c.Protocol, _ = getenv("PROTOCOL")
+	c.Compression, _ = getenv("COMPRESSION")
+	c.CACertificate, _ = getenv("CERTIFICATE")
+	c.ClientCertificate, _ = getenv("CLIENT_CERTIFICATE")
+	c.ClientKey, _ = getenv("CLIENT_KEY")
+
+	if v, key := getenv("INSECURE"); v != "" {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		c.Insecure = insecure
+	}
+
+	if v, key := getenv("TIMEOUT"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		c.Timeout = time.Duration(ms) * time.Millisecond
+	}
+
+	v, key := getenv("HEADERS")
+	headers, err := parseHeaders(v)
+	if err != nil {
+		return c, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	c.Headers = headers
+
+	return c, nil
+}
+
+// parseHeaders decodes the W3C-baggage-like "key1=value1,key2=value2" format,
+// with URL-encoded values.
+func parseHeaders(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("missing '=' in %q", pair)
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		headers[strings.TrimSpace(k)] = value
+	}
+	return headers, nil
+}
+
+// target is the resolved form of an ExporterConfig endpoint.
+type target struct {
+	host      string
+	path      string
+	insecure  bool
+	signalURL bool
+	// defaulted is set when no endpoint was configured.
+	defaulted bool
+}
+
+// urlPath returns the HTTP path of signalPath, e.g. /v1/traces, under the
+// endpoint, or the path of a per-signal endpoint as is.
+func (t target) urlPath(signalPath string) string {
+	if t.signalURL {
+		return cmp.Or(t.path, "/")
+	}
+	return t.path + signalPath
+}
+
+// resolve fills in the defaults and validates the configuration.
+func (c ExporterConfig) resolve() (ExporterConfig, target, error) {
+	if c.Protocol == "" {
+		c.Protocol = ProtocolGRPC
+	}
+	defaulted := c.Endpoint == ""
+	if defaulted {
+		c.Endpoint = "http://localhost:4317"
+		if c.Protocol == ProtocolHTTPProtobuf {
+			c.Endpoint = "http://localhost:4318"
+		}
+	}
+	if c.Protocol != ProtocolGRPC && c.Protocol != ProtocolHTTPProtobuf {
+		return c, target{}, fmt.Errorf("unsupported OTLP protocol %q", c.Protocol)
+	}
+	if c.Compression != "" && c.Compression != "gzip" && c.Compression != "none" {
+		return c, target{}, fmt.Errorf("unsupported OTLP compression %q", c.Compression)
+	}
+
+	t := target{host: c.Endpoint, insecure: c.Insecure, signalURL: c.SignalURL && !defaulted, defaulted: defaulted}
+	if strings.Contains(c.Endpoint, "://") {
+		u, err := url.Parse(c.Endpoint)
+		if err != nil {
+			return c, target{}, fmt.Errorf("invalid OTLP endpoint: %w", err)
+		}
+		t.host = u.Host
+		t.path = strings.TrimRight(u.Path, "/")
+		t.insecure = u.Scheme == "http"
+	}
+	return c, t, nil
+}
+
+func (c ExporterConfig) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if c.CACertificate != "" {
+		pem, err := os.ReadFile(c.CACertificate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OTLP CA certificate: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", c.CACertificate)
+		}
+	}
+
+	if c.ClientCertificate != "" || c.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCertificate, c.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load OTLP client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// String describes the destination, for the startup log. A default endpoint
+// is flagged as such, so it is not mistaken for a configured one.
+func (c ExporterConfig) String() string {
+	c, t, _ := c.resolve()
+	endpoint := c.Endpoint
+	if t.defaulted {
+		endpoint += " (default, no endpoint configured)"
+	}
+	security := "tls"
+	if t.insecure {
+		security = "insecure"
+	} else if c.ClientCertificate != "" {
+		security = "mtls"
+	}
+	compression := c.Compression
+	if compression == "" {
+		compression = "none"
+	}
+	return fmt.Sprintf("%s via %s (%s, compression: %s)", endpoint, c.Protocol, security, compression)
+}
+
+func newTraceExporter(ctx context.Context, c ExporterConfig) (sdktrace.SpanExporter, error) {
+	c, t, err := c.resolve()
+	if err != nil {
+		return nil, err
+	}
+	var tlsCfg *tls.Config
+	if !t.insecure {
+		if tlsCfg, err = c.tlsConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Protocol == ProtocolHTTPProtobuf {
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(t.host), otlptracehttp.WithURLPath(t.urlPath("/v1/traces")), otlptracehttp.WithHeaders(c.Headers)}
+		if t.insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		} else {
+			opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsCfg))
+		}
+		if c.Compression == "gzip" {
+			opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+		}
+		if c.Timeout > 0 {
+			opts = append(opts, otlptracehttp.WithTimeout(c.Timeout))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(t.host), otlptracegrpc.WithHeaders(c.Headers)}
+	if t.insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	} else {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsCfg)))
+	}
+	if c.Compression == "gzip" {
+		opts = append(opts, otlptracegrpc.WithCompressor("gzip"))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, otlptracegrpc.WithTimeout(c.Timeout))
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+func newMetricExporter(ctx context.Context, c ExporterConfig) (sdkmetric.Exporter, error) {
+	c, t, err := c.resolve()
+	if err != nil {
+		return nil, err
+	}
+	var tlsCfg *tls.Config
+	if !t.insecure {
+		if tlsCfg, err = c.tlsConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Protocol == ProtocolHTTPProtobuf {
+		opts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(t.host), otlpmetrichttp.WithURLPath(t.urlPath("/v1/metrics")), otlpmetrichttp.WithHeaders(c.Headers)}
+		if t.insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		} else {
+			opts = append(opts, otlpmetrichttp.WithTLSClientConfig(tlsCfg))
+		}
+		if c.Compression == "gzip" {
+			opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+		}
+		if c.Timeout > 0 {
+			opts = append(opts, otlpmetrichttp.WithTimeout(c.Timeout))
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	}
+
+	opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(t.host), otlpmetricgrpc.WithHeaders(c.Headers)}
+	if t.insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	} else {
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(tlsCfg)))
+	}
+	if c.Compression == "gzip" {
+		opts = append(opts, otlpmetricgrpc.WithCompressor("gzip"))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, otlpmetricgrpc.WithTimeout(c.Timeout))
+	}
+	return otlpmetricgrpc.New(ctx, opts...)
+}
+
+func newLogExporter(ctx context.Context, c ExporterConfig) (sdklog.Exporter, error) {
+	c, t, err := c.resolve()
+	if err != nil {
+		return nil, err
+	}
+	var tlsCfg *tls.Config
+	if !t.insecure {
+		if tlsCfg, err = c.tlsConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Protocol == ProtocolHTTPProtobuf {
+		opts := []otlploghttp.Option{otlploghttp.WithEndpoint(t.host), otlploghttp.WithURLPath(t.urlPath("/v1/logs")), otlploghttp.WithHeaders(c.Headers)}
+		if t.insecure {
+			opts = append(opts, otlploghttp.WithInsecure())
+		} else {
+			opts = append(opts, otlploghttp.WithTLSClientConfig(tlsCfg))
+		}
+		if c.Compression == "gzip" {
+			opts = append(opts, otlploghttp.WithCompression(otlploghttp.GzipCompression))
+		}
+		if c.Timeout > 0 {
+			opts = append(opts, otlploghttp.WithTimeout(c.Timeout))
+		}
+		return otlploghttp.New(ctx, opts...)
+	}
+
+	opts := []otlploggrpc.Option{otlploggrpc.WithEndpoint(t.host), otlploggrpc.WithHeaders(c.Headers)}
+	if t.insecure {
+		opts = append(opts, otlploggrpc.WithInsecure())
+	} else {
+		opts = append(opts, otlploggrpc.WithTLSCredentials(credentials.NewTLS(tlsCfg)))
+	}
+	if c.Compression == "gzip" {
+		opts = append(opts, otlploggrpc.WithCompressor("gzip"))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, otlploggrpc.WithTimeout(c.Timeout))
+	}
+	return otlploggrpc.New(ctx, opts...)
+}
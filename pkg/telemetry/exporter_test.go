@@ -0,0 +1,138 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestExporterConfigFromEnv(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "https://collector.example.com:4318")
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "api-key=secret,x-tenant=a%20b")
+	t.Setenv("OTEL_EXPORTER_OTLP_COMPRESSION", "gzip")
+	t.Setenv("OTEL_EXPORTER_OTLP_TIMEOUT", "2500")
+
+	c, err := ExporterConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if c.Protocol != ProtocolHTTPProtobuf {
+		t.Errorf("Expected Protocol to be %s, but got %s", ProtocolHTTPProtobuf, c.Protocol)
+	}
+	if c.Headers["api-key"] != "secret" || c.Headers["x-tenant"] != "a b" {
+		t.Errorf("Expected decoded headers, but got %v", c.Headers)
+	}
+	if c.Timeout != 2500*time.Millisecond {
+		t.Errorf("Expected Timeout to be 2.5s, but got %v", c.Timeout)
+	}
+	if got := c.String(); got != "https://collector.example.com:4318 via http/protobuf (tls, compression: gzip)" {
+		t.Errorf("Unexpected description: %s", got)
+	}
+}
+
+func TestSignalExporterConfigFromEnvPrefersSignalVariables(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "https://collector.example.com:4318")
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "api-key=generic")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "https://traces.example.com/ingest/spans")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS", "api-key=traces")
+
+	traces, err := SignalExporterConfigFromEnv("traces")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if traces.Endpoint != "https://traces.example.com/ingest/spans" || !traces.SignalURL || traces.Headers["api-key"] != "traces" {
+		t.Errorf("Expected the traces variables to win, but got %+v", traces)
+	}
+	if traces.Protocol != ProtocolHTTPProtobuf {
+		t.Errorf("Expected the generic protocol as fallback, but got %s", traces.Protocol)
+	}
+	_, target, _ := traces.resolve()
+	if path := target.urlPath("/v1/traces"); path != "/ingest/spans" {
+		t.Errorf("Expected a per-signal endpoint to be used as is, but got %s", path)
+	}
+
+	metrics, err := SignalExporterConfigFromEnv("metrics")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if metrics.Endpoint != "https://collector.example.com:4318" || metrics.SignalURL || metrics.Headers["api-key"] != "generic" {
+		t.Errorf("Expected metrics to fall back to the generic variables, but got %+v", metrics)
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_LOGS_TIMEOUT", "soon")
+	if _, err := SignalExporterConfigFromEnv("logs"); err == nil || !strings.Contains(err.Error(), "OTEL_EXPORTER_OTLP_LOGS_TIMEOUT") {
+		t.Errorf("Expected an error naming OTEL_EXPORTER_OTLP_LOGS_TIMEOUT, but got %v", err)
+	}
+}
+
+func TestExporterConfigFromEnvRejectsInvalidValues(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "no-separator")
+
+	if _, err := ExporterConfigFromEnv(); err == nil {
+		t.Errorf("Expected an error for malformed headers, but got none")
+	}
+}
+
+func TestExporterConfigDefaults(t *testing.T) {
+	c, target, err := ExporterConfig{}.resolve()
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if c.Protocol != ProtocolGRPC || target.host != "localhost:4317" || !target.insecure {
+		t.Errorf("Expected insecure gRPC to localhost:4317, but got %s %s (insecure: %v)", c.Protocol, target.host, target.insecure)
+	}
+
+	c, target, _ = ExporterConfig{Protocol: ProtocolHTTPProtobuf}.resolve()
+	if target.host != "localhost:4318" {
+		t.Errorf("Expected HTTP to default to localhost:4318, but got %s", target.host)
+	}
+
+	if got := (ExporterConfig{}).String(); got != "http://localhost:4317 (default, no endpoint configured) via grpc (insecure, compression: none)" {
+		t.Errorf("Expected the default endpoint to be flagged, but got %s", got)
+	}
+
+	if _, _, err := (ExporterConfig{Protocol: "thrift"}).resolve(); err == nil {
+		t.Errorf("Expected an error for an unsupported protocol, but got none")
+	}
+}
+
+func TestHTTPTraceExporterUsesEndpointPath(t *testing.T) {
+	paths := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api-key") != "secret" {
+			t.Errorf("Expected the api-key header to be sent")
+		}
+		paths <- r.URL.Path
+	}))
+	defer srv.Close()
+
+	exporter, err := newTraceExporter(context.Background(), ExporterConfig{
+		Endpoint: srv.URL + "/otlp",
+		Protocol: ProtocolHTTPProtobuf,
+		Headers:  map[string]string{"api-key": "secret"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	_, span := tp.Tracer("test").Start(context.Background(), "TestSpan")
+	span.End()
+	tp.Shutdown(context.Background())
+
+	select {
+	case path := <-paths:
+		if !strings.HasSuffix(path, "/otlp/v1/traces") {
+			t.Errorf("Expected spans to be posted to /otlp/v1/traces, but got %s", path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the exporter")
+	}
+}
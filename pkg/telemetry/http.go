@@ -0,0 +1,306 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sensitiveQueryParams are query parameters whose values never reach url.full,
+// such as WeatherAPI's key.
+var sensitiveQueryParams = map[string]bool{
+	"key":          true,
+	"api_key":      true,
+	"apikey":       true,
+	"token":        true,
+	"access_token": true,
+	"sig":          true,
+	"signature":    true,
+}
+
+// NewServerMiddleware starts a server span for every request, continuing the
+// trace propagated by the caller, and returns the trace to the client in the
+// traceparent and X-Trace-Id response headers. route returns the matched route
+// template (e.g. /temperature/{cep}) once the router has handled the request;
+// it names the span and fills http.route. Only 5xx responses mark the span as
+// an error, as 4xx are the client's fault.
+func NewServerMiddleware(tracerName string, route func(*http.Request) string) func(http.Handler) http.Handler {
+	tracer := otel.Tracer(tracerName)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, r.Method,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(serverRequestAttributes(r)...),
+			)
+			defer span.End()
+
+			if sc := span.SpanContext(); sc.IsValid() {
+				w.Header().Set("traceparent", fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags()))
+				w.Header().Set("X-Trace-Id", sc.TraceID().String())
+			}
+
+			rw := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			// The route template is only known once the router has matched
+			// the request.
+			if template := route(r); template != "" {
+				span.SetName(r.Method + " " + template)
+				span.SetAttributes(semconv.HTTPRoute(template))
+			}
+
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttributes(
+				semconv.HTTPResponseStatusCode(status),
+				semconv.HTTPResponseBodySize(rw.bytes),
+			)
+			if status >= http.StatusInternalServerError {
+				span.SetAttributes(semconv.ErrorTypeKey.String(strconv.Itoa(status)))
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+		})
+	}
+}
+
+// TraceID returns the ID of the trace active in ctx, or "" when there is none,
+// for error bodies that support can paste into Zipkin.
+func TraceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+func serverRequestAttributes(r *http.Request) []attribute.KeyValue {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(r.Method),
+		semconv.URLScheme(scheme),
+		semconv.URLPath(r.URL.Path),
+		semconv.NetworkProtocolVersion(protocolVersion(r.ProtoMajor, r.ProtoMinor)),
+	}
+	attrs = append(attrs, hostAttributes(r.Host, semconv.ServerAddress, semconv.ServerPort)...)
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		attrs = append(attrs, semconv.ClientAddress(host))
+	}
+	attrs = append(attrs, hostAttributes(r.RemoteAddr, semconv.NetworkPeerAddress, semconv.NetworkPeerPort)...)
+	if ua := r.UserAgent(); ua != "" {
+		attrs = append(attrs, semconv.UserAgentOriginal(ua))
+	}
+	if r.ContentLength > 0 {
+		attrs = append(attrs, semconv.HTTPRequestBodySize(int(r.ContentLength)))
+	}
+	return attrs
+}
+
+// responseRecorder captures the status code and body size written by the
+// handler.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// NewTransport wraps base (http.DefaultTransport when nil) so every outgoing
+// request gets a client span and carries the trace context. Both 4xx and 5xx
+// responses mark the span as an error. The span ends when the response body
+// is closed or fully read.
+func NewTransport(tracerName string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, tracer: otel.Tracer(tracerName)}
+}
+
+type transport struct {
+	base   http.RoundTripper
+	tracer trace.Tracer
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(clientRequestAttributes(req)...),
+	)
+
+	// The peer address is only known once a connection is picked.
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			span.SetAttributes(hostAttributes(info.Conn.RemoteAddr().String(), semconv.NetworkPeerAddress, semconv.NetworkPeerPort)...)
+		},
+	})
+
+	// A RoundTripper must not modify the caller's request.
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.SetAttributes(semconv.ErrorTypeKey.String(errorType(err)))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return nil, err
+	}
+
+	span.SetAttributes(
+		semconv.HTTPResponseStatusCode(resp.StatusCode),
+		semconv.NetworkProtocolVersion(protocolVersion(resp.ProtoMajor, resp.ProtoMinor)),
+	)
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetAttributes(semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+
+	if resp.Body == nil || resp.Body == http.NoBody {
+		span.SetAttributes(semconv.HTTPResponseBodySize(0))
+		span.End()
+		return resp, nil
+	}
+	resp.Body = &spanBody{ReadCloser: resp.Body, span: span}
+	return resp, nil
+}
+
+func clientRequestAttributes(req *http.Request) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.URLFull(redactURL(req.URL)),
+	}
+	host := req.URL.Host
+	if req.URL.Port() == "" {
+		port := "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(req.URL.Hostname(), port)
+	}
+	attrs = append(attrs, hostAttributes(host, semconv.ServerAddress, semconv.ServerPort)...)
+	if req.ContentLength > 0 {
+		attrs = append(attrs, semconv.HTTPRequestBodySize(int(req.ContentLength)))
+	}
+	return attrs
+}
+
+// spanBody ends the client span once the caller is done with the body,
+// recording how many bytes were read.
+type spanBody struct {
+	io.ReadCloser
+	span  trace.Span
+	bytes int
+	once  sync.Once
+}
+
+func (b *spanBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes += n
+	if err == io.EOF {
+		b.end()
+	}
+	return n, err
+}
+
+func (b *spanBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.end()
+	return err
+}
+
+func (b *spanBody) end() {
+	b.once.Do(func() {
+		b.span.SetAttributes(semconv.HTTPResponseBodySize(b.bytes))
+		b.span.End()
+	})
+}
+
+// hostAttributes splits a host:port pair into the given address and port
+// attributes. A host without a port only yields the address.
+func hostAttributes(hostport string, address func(string) attribute.KeyValue, port func(int) attribute.KeyValue) []attribute.KeyValue {
+	host, p, err := net.SplitHostPort(hostport)
+	if err != nil {
+		if hostport == "" {
+			return nil
+		}
+		return []attribute.KeyValue{address(hostport)}
+	}
+	attrs := []attribute.KeyValue{address(host)}
+	if n, err := strconv.Atoi(p); err == nil {
+		attrs = append(attrs, port(n))
+	}
+	return attrs
+}
+
+// redactURL drops credentials and the values of sensitive query parameters.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.UserPassword("REDACTED", "REDACTED")
+	}
+	if redacted.RawQuery != "" {
+		query := redacted.Query()
+		for name := range query {
+			if sensitiveQueryParams[name] {
+				query.Set(name, "REDACTED")
+			}
+		}
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
+
+func protocolVersion(major, minor int) string {
+	if minor == 0 && major >= 2 {
+		return strconv.Itoa(major)
+	}
+	return strconv.Itoa(major) + "." + strconv.Itoa(minor)
+}
+
+// errorType describes a transport error as a timeout or by its Go type.
+func errorType(err error) string {
+	if e, ok := err.(net.Error); ok && e.Timeout() {
+		return "timeout"
+	}
+	return fmt.Sprintf("%T", err)
+}
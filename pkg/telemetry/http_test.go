@@ -0,0 +1,146 @@
+package telemetry
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newSpanRecorder() *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return sr
+}
+
+func spanAttribute(s sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestServerMiddlewareNamesSpanAfterRoute(t *testing.T) {
+	sr := newSpanRecorder()
+
+	handler := NewServerMiddleware("test", func(r *http.Request) string {
+		return "/temperature/{cep}"
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !trace.SpanFromContext(r.Context()).SpanContext().IsValid() {
+			t.Errorf("Expected the handler to run inside the server span")
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not found"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/temperature/01001000", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, but got %d", len(spans))
+	}
+	s := spans[0]
+	if s.Name() != "GET /temperature/{cep}" {
+		t.Errorf("Expected span name GET /temperature/{cep}, but got %s", s.Name())
+	}
+	if s.SpanKind() != trace.SpanKindServer {
+		t.Errorf("Expected a server span, but got %s", s.SpanKind())
+	}
+	if route := spanAttribute(s, "http.route").AsString(); route != "/temperature/{cep}" {
+		t.Errorf("Expected http.route /temperature/{cep}, but got %s", route)
+	}
+	if status := spanAttribute(s, "http.response.status_code").AsInt64(); status != http.StatusNotFound {
+		t.Errorf("Expected http.response.status_code 404, but got %d", status)
+	}
+	if size := spanAttribute(s, "http.response.body.size").AsInt64(); size != 9 {
+		t.Errorf("Expected http.response.body.size 9, but got %d", size)
+	}
+	if s.Status().Code != codes.Unset {
+		t.Errorf("Expected a 4xx to leave the server span status unset, but got %s", s.Status().Code)
+	}
+	traceparent := "00-" + s.SpanContext().TraceID().String() + "-" + s.SpanContext().SpanID().String() + "-01"
+	if got := rec.Header().Get("traceparent"); got != traceparent {
+		t.Errorf("Expected traceparent response header %s, but got %s", traceparent, got)
+	}
+	if got := rec.Header().Get("X-Trace-Id"); got != s.SpanContext().TraceID().String() {
+		t.Errorf("Expected X-Trace-Id response header %s, but got %s", s.SpanContext().TraceID(), got)
+	}
+}
+
+func TestServerMiddlewareMarksServerErrors(t *testing.T) {
+	sr := newSpanRecorder()
+
+	handler := NewServerMiddleware("test", func(r *http.Request) string { return "" })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	s := sr.Ended()[0]
+	if s.Status().Code != codes.Error {
+		t.Errorf("Expected a 5xx to mark the span as an error, but got %s", s.Status().Code)
+	}
+	if s.Name() != "GET" {
+		t.Errorf("Expected span name GET without a route, but got %s", s.Name())
+	}
+}
+
+func TestTransportCreatesClientSpan(t *testing.T) {
+	sr := newSpanRecorder()
+
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("unavailable"))
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: NewTransport("test", nil)}
+	resp, err := client.Get(upstream.URL + "/v1/current.json?key=secret&q=Sao+Paulo")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, but got %d", len(spans))
+	}
+	s := spans[0]
+	if s.SpanKind() != trace.SpanKindClient {
+		t.Errorf("Expected a client span, but got %s", s.SpanKind())
+	}
+	if traceparent == "" {
+		t.Errorf("Expected the request to carry a traceparent header")
+	}
+	if s.Status().Code != codes.Error {
+		t.Errorf("Expected a 5xx to mark the client span as an error, but got %s", s.Status().Code)
+	}
+	if size := spanAttribute(s, "http.response.body.size").AsInt64(); size != 11 {
+		t.Errorf("Expected http.response.body.size 11, but got %d", size)
+	}
+	if peer := spanAttribute(s, "network.peer.address").AsString(); peer != "127.0.0.1" {
+		t.Errorf("Expected network.peer.address 127.0.0.1, but got %s", peer)
+	}
+
+	full, _ := url.Parse(spanAttribute(s, "url.full").AsString())
+	if key := full.Query().Get("key"); key != "REDACTED" {
+		t.Errorf("Expected the API key to be redacted from url.full, but got %s", key)
+	}
+}
@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTraceHandlerAddsTraceContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(traceHandler{slog.NewJSONHandler(&buf, nil)})
+
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "TestSpan")
+	defer span.End()
+
+	logger.InfoContext(ctx, "with span")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON record, but got %q", buf.String())
+	}
+	if record["trace_id"] != span.SpanContext().TraceID().String() {
+		t.Errorf("Expected trace_id to be %s, but got %v", span.SpanContext().TraceID(), record["trace_id"])
+	}
+	if record["span_id"] != span.SpanContext().SpanID().String() {
+		t.Errorf("Expected span_id to be %s, but got %v", span.SpanContext().SpanID(), record["span_id"])
+	}
+}
+
+func TestTraceHandlerWithoutSpan(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(traceHandler{slog.NewJSONHandler(&buf, nil)})
+
+	logger.InfoContext(context.Background(), "without span")
+
+	if bytes.Contains(buf.Bytes(), []byte("trace_id")) {
+		t.Errorf("Expected no trace_id without an active span, but got %q", buf.String())
+	}
+}
+
+func TestFanoutHandlerRespectsLevel(t *testing.T) {
+	var first, second bytes.Buffer
+	logger := slog.New(fanoutHandler{
+		level: slog.LevelWarn,
+		handlers: []slog.Handler{
+			slog.NewTextHandler(&first, nil),
+			slog.NewTextHandler(&second, nil),
+		},
+	})
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+
+	for i, buf := range []*bytes.Buffer{&first, &second} {
+		if bytes.Contains(buf.Bytes(), []byte("dropped")) {
+			t.Errorf("Expected handler %d to drop records below the level", i)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte("kept")) {
+			t.Errorf("Expected handler %d to receive the warning", i)
+		}
+	}
+}
+
+func TestNewLoggerRejectsInvalidLevel(t *testing.T) {
+	if _, err := NewLogger("test", "loud", "text", false); err == nil {
+		t.Errorf("Expected an error for an invalid level, but got none")
+	}
+}
@@ -0,0 +1,269 @@
+// Package telemetry bootstraps OpenTelemetry for the services: resource,
+// tracer, meter and logger providers, propagators and slog, all exported over
+// OTLP to the collector.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+type config struct {
+	serviceName string
+	traces      ExporterConfig
+	metrics     ExporterConfig
+	logs        ExporterConfig
+	sampling    SamplingConfig
+	propagators []string
+	baggageKeys []string
+	logLevel    string
+	logFormat   string
+	logConsole  bool
+}
+
+// Option customizes Setup.
+type Option func(*config)
+
+// WithServiceName sets the service name reported in the resource.
+// OTEL_SERVICE_NAME, when set, takes precedence.
+func WithServiceName(name string) Option {
+	return func(c *config) {
+		c.serviceName = name
+	}
+}
+
+// WithExporter replaces, for every signal, the exporter configuration read
+// from the OTEL_EXPORTER_OTLP_* environment variables.
+func WithExporter(exporter ExporterConfig) Option {
+	return func(c *config) {
+		c.traces, c.metrics, c.logs = exporter, exporter, exporter
+	}
+}
+
+// WithEndpoint only overrides the collector endpoint of every signal, keeping
+// the rest of the exporter configuration.
+func WithEndpoint(endpoint string) Option {
+	return func(c *config) {
+		for _, e := range []*ExporterConfig{&c.traces, &c.metrics, &c.logs} {
+			e.Endpoint = endpoint
+			e.SignalURL = false
+		}
+	}
+}
+
+// WithSampling replaces the sampling configuration read from
+// OTEL_TRACES_SAMPLER, OTEL_TRACES_SAMPLER_ARG and TRACES_KEEP_*.
+func WithSampling(sampling SamplingConfig) Option {
+	return func(c *config) {
+		c.sampling = sampling
+	}
+}
+
+// WithPropagators replaces the propagators read from OTEL_PROPAGATORS, e.g.
+// "tracecontext", "baggage", "b3", "b3multi" or "jaeger".
+func WithPropagators(names ...string) Option {
+	return func(c *config) {
+		c.propagators = names
+	}
+}
+
+// WithBaggageKeys copies the named baggage members (e.g. "tenant.id") onto
+// every span and log record.
+func WithBaggageKeys(keys ...string) Option {
+	return func(c *config) {
+		c.baggageKeys = keys
+	}
+}
+
+// WithLogLevel sets the minimum slog level (debug, info, warn or error).
+func WithLogLevel(level string) Option {
+	return func(c *config) {
+		c.logLevel = level
+	}
+}
+
+// WithConsoleLog also writes log records to stdout, as "text" or "json", for
+// local development.
+func WithConsoleLog(enabled bool, format string) Option {
+	return func(c *config) {
+		c.logConsole = enabled
+		c.logFormat = format
+	}
+}
+
+// Setup installs the global tracer, meter and logger providers, the text map
+// propagator and the default slog logger. The returned function flushes and
+// shuts everything down.
+func Setup(ctx context.Context, opts ...Option) (func(context.Context) error, error) {
+	traces, err := SignalExporterConfigFromEnv("traces")
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := SignalExporterConfigFromEnv("metrics")
+	if err != nil {
+		return nil, err
+	}
+	logs, err := SignalExporterConfigFromEnv("logs")
+	if err != nil {
+		return nil, err
+	}
+
+	sampling, err := SamplingConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	c := config{
+		traces:      traces,
+		metrics:     metrics,
+		logs:        logs,
+		sampling:    sampling,
+		propagators: PropagatorsFromEnv(),
+		logLevel:    "info",
+		logFormat:   "text",
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	res, err := newResource(ctx, c.serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	var shutdowns []func(context.Context) error
+	shutdown := func(ctx context.Context) error {
+		var errs []error
+		// Providers are shut down in reverse order.
+		for i := len(shutdowns) - 1; i >= 0; i-- {
+			errs = append(errs, shutdowns[i](ctx))
+		}
+		return errors.Join(errs...)
+	}
+
+	for _, init := range []func(context.Context, *resource.Resource, config) (func(context.Context) error, error){
+		initTracerProvider,
+		initMeterProvider,
+		initLoggerProvider,
+	} {
+		s, err := init(ctx, res, c)
+		if err != nil {
+			return nil, errors.Join(err, shutdown(ctx))
+		}
+		shutdowns = append(shutdowns, s)
+	}
+
+	// Set global propagator (the default is no-op).
+	propagator, err := NewPropagator(c.propagators)
+	if err != nil {
+		return nil, errors.Join(err, shutdown(ctx))
+	}
+	otel.SetTextMapPropagator(propagator)
+
+	name, _ := res.Set().Value(semconv.ServiceNameKey)
+	logger, err := NewLogger(name.AsString(), c.logLevel, c.logFormat, c.logConsole)
+	if err != nil {
+		return nil, errors.Join(err, shutdown(ctx))
+	}
+	if len(c.baggageKeys) > 0 {
+		logger = slog.New(baggageHandler{Handler: logger.Handler(), keys: c.baggageKeys})
+	}
+	slog.SetDefault(logger)
+	slog.Info("Exporting telemetry", "traces", c.traces.String(), "metrics", c.metrics.String(), "logs", c.logs.String())
+
+	return shutdown, nil
+}
+
+// newResource describes the service. OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES override the attributes set in code.
+func newResource(ctx context.Context, serviceName string) (*resource.Resource, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			// The service name used to display traces in backends
+			semconv.ServiceName(serviceName),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+	return res, nil
+}
+
+// Initializes an OTLP exporter, and configures the corresponding trace provider.
+func initTracerProvider(ctx context.Context, res *resource.Resource, c config) (func(context.Context) error, error) {
+	// Set up a trace exporter
+	traceExporter, err := newTraceExporter(ctx, c.traces)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	sampler, err := c.sampling.sampler()
+	if err != nil {
+		return nil, err
+	}
+
+	// Register the trace exporter with a TracerProvider, using a batch
+	// span processor to aggregate spans before export.
+	var processor sdktrace.SpanProcessor = sdktrace.NewBatchSpanProcessor(traceExporter)
+	if c.sampling.keeps() {
+		// Traces dropped by the sampler are still recorded, and exported
+		// only if they fail or are slow.
+		processor = newKeepProcessor(processor, c.sampling)
+	}
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(res),
+		sdktrace.WithSpanProcessor(baggageSpanProcessor{keys: c.baggageKeys}),
+		sdktrace.WithSpanProcessor(processor),
+	)
+	otel.SetTracerProvider(tracerProvider)
+
+	// Shutdown will flush any remaining spans and shut down the exporter.
+	return tracerProvider.Shutdown, nil
+}
+
+// Initializes an OTLP exporter, and configures the corresponding meter provider.
+func initMeterProvider(ctx context.Context, res *resource.Resource, c config) (func(context.Context) error, error) {
+	metricExporter, err := newMetricExporter(ctx, c.metrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics exporter: %w", err)
+	}
+
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)),
+		sdkmetric.WithResource(res),
+	)
+	otel.SetMeterProvider(meterProvider)
+
+	// Shutdown will flush any remaining metrics and shut down the exporter.
+	return meterProvider.Shutdown, nil
+}
+
+// Initializes an OTLP exporter, and configures the corresponding logger provider.
+func initLoggerProvider(ctx context.Context, res *resource.Resource, c config) (func(context.Context) error, error) {
+	logExporter, err := newLogExporter(ctx, c.logs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log exporter: %w", err)
+	}
+
+	loggerProvider := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(logExporter)),
+		sdklog.WithResource(res),
+	)
+	global.SetLoggerProvider(loggerProvider)
+
+	// Shutdown will flush any remaining log records and shut down the exporter.
+	return loggerProvider.Shutdown, nil
+}
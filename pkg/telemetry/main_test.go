@@ -0,0 +1,39 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+func TestNewResourceUsesServiceName(t *testing.T) {
+	res, err := newResource(context.Background(), "service-a")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	name, _ := res.Set().Value(semconv.ServiceNameKey)
+	if name.AsString() != "service-a" {
+		t.Errorf("Expected service.name to be service-a, but got %s", name.AsString())
+	}
+}
+
+func TestNewResourceHonorsEnvironment(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "from-env")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=dev")
+
+	res, err := newResource(context.Background(), "service-a")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	name, _ := res.Set().Value(semconv.ServiceNameKey)
+	if name.AsString() != "from-env" {
+		t.Errorf("Expected OTEL_SERVICE_NAME to override service.name, but got %s", name.AsString())
+	}
+	env, ok := res.Set().Value("deployment.environment")
+	if !ok || env.AsString() != "dev" {
+		t.Errorf("Expected deployment.environment to be dev, but got %s", env.AsString())
+	}
+}
@@ -0,0 +1,118 @@
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// durationBuckets are the bucket boundaries, in seconds, of every duration
+// histogram.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// NewMetricsMiddleware records RED metrics (rate, errors and duration) for
+// every request, by route template, status code and the given baggage keys,
+// with a bounded number of values per key.
+// route returns the matched route template, as in NewServerMiddleware. Only
+// 5xx responses count as errors: 4xx are answers to bad input, not failures
+// of the service.
+func NewMetricsMiddleware(meterName string, route func(*http.Request) string, baggageKeys []string) func(http.Handler) http.Handler {
+	meter := otel.Meter(meterName)
+	metricBaggage := newMetricBaggage(baggageKeys)
+
+	requests, _ := meter.Int64Counter("http.server.requests",
+		metric.WithDescription("Number of HTTP requests handled."),
+	)
+	failures, _ := meter.Int64Counter("http.server.errors",
+		metric.WithDescription("Number of HTTP requests answered with a 5xx status."),
+	)
+	duration, _ := meter.Float64Histogram("http.server.request.duration",
+		metric.WithDescription("Duration of HTTP requests."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &responseRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rw, r)
+
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			ctx := r.Context()
+			attrs := metric.WithAttributes(append([]attribute.KeyValue{
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route(r)),
+				attribute.Int("http.response.status_code", status),
+			}, metricBaggage.attributes(ctx)...)...)
+
+			requests.Add(ctx, 1, attrs)
+			if status >= http.StatusInternalServerError {
+				failures.Add(ctx, 1, attrs)
+			}
+			duration.Record(ctx, time.Since(start).Seconds(), attrs)
+		})
+	}
+}
+
+// RequestLogger logs one structured line per request, replacing chi's
+// plain-text middleware.Logger.
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rw, r)
+
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		slog.InfoContext(r.Context(), "Request completed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", rw.bytes,
+			"duration", time.Since(start),
+		)
+	})
+}
+
+// UpstreamRecorder records the upstream.request.duration histogram of calls
+// to upstream APIs, by upstream and outcome.
+type UpstreamRecorder struct {
+	duration metric.Float64Histogram
+}
+
+// NewUpstreamRecorder builds a recorder on the meter meterName.
+func NewUpstreamRecorder(meterName string) *UpstreamRecorder {
+	duration, _ := otel.Meter(meterName).Float64Histogram("upstream.request.duration",
+		metric.WithDescription("Duration of calls to upstream APIs, by provider and outcome."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+	return &UpstreamRecorder{duration: duration}
+}
+
+// Record records a call to upstream that started at start and failed when err
+// is not nil.
+func (u *UpstreamRecorder) Record(ctx context.Context, upstream string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	u.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("upstream", upstream),
+		attribute.String("outcome", outcome),
+	))
+}
@@ -0,0 +1,100 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMetricsMiddlewareCountsRequestsByRoute(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	route := func(r *http.Request) string { return r.Pattern }
+	srv := httptest.NewServer(NewMetricsMiddleware("test", route, nil)(mux))
+	defer srv.Close()
+
+	for _, path := range []string{"/ok/1", "/ok/2", "/fail"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	counts := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				continue
+			}
+			for _, dp := range sum.DataPoints {
+				route, _ := dp.Attributes.Value(attribute.Key("http.route"))
+				counts[m.Name+" "+route.AsString()] += dp.Value
+			}
+		}
+	}
+
+	want := map[string]int64{
+		"http.server.requests /ok/{id}": 2,
+		"http.server.requests /fail":    1,
+		"http.server.errors /fail":      1,
+	}
+	for key, n := range want {
+		if counts[key] != n {
+			t.Errorf("Expected %s to be %d, but got %d", key, n, counts[key])
+		}
+	}
+	if n := counts["http.server.errors /ok/{id}"]; n != 0 {
+		t.Errorf("Expected no errors on /ok/{id}, but got %d", n)
+	}
+}
+
+func TestUpstreamRecorderRecordsOutcome(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	// Two recorders on the same meter share one instrument
+	NewUpstreamRecorder("test").Record(context.Background(), "viacep", time.Now(), nil)
+	NewUpstreamRecorder("test").Record(context.Background(), "weatherapi", time.Now(), errors.New("bad gateway"))
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	counts := map[string]uint64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			hist, ok := m.Data.(metricdata.Histogram[float64])
+			if !ok || m.Name != "upstream.request.duration" {
+				continue
+			}
+			for _, dp := range hist.DataPoints {
+				upstream, _ := dp.Attributes.Value(attribute.Key("upstream"))
+				outcome, _ := dp.Attributes.Value(attribute.Key("outcome"))
+				counts[upstream.AsString()+" "+outcome.AsString()] += dp.Count
+			}
+		}
+	}
+	if counts["viacep success"] != 1 || counts["weatherapi error"] != 1 || len(counts) != 2 {
+		t.Errorf("Expected one success of viacep and one error of weatherapi, but got %v", counts)
+	}
+}
@@ -0,0 +1,57 @@
+package telemetry
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// defaultPropagators are used when OTEL_PROPAGATORS is not set, as the
+// specification does.
+var defaultPropagators = []string{"tracecontext", "baggage"}
+
+// PropagatorsFromEnv reads the comma-separated OTEL_PROPAGATORS list.
+func PropagatorsFromEnv() []string {
+	v := os.Getenv("OTEL_PROPAGATORS")
+	if v == "" {
+		return defaultPropagators
+	}
+	var names []string
+	for _, name := range strings.Split(v, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// NewPropagator builds a composite propagator from tracecontext, baggage, b3
+// (single header), b3multi and jaeger. Every configured format is extracted
+// and injected, so a trace arriving with B3 headers leaves with all of them.
+// "none" disables propagation.
+func NewPropagator(names []string) (propagation.TextMapPropagator, error) {
+	var propagators []propagation.TextMapPropagator
+	for _, name := range names {
+		switch name {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		case "b3":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case "b3multi":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case "jaeger":
+			propagators = append(propagators, jaeger.Jaeger{})
+		case "none":
+			return propagation.NewCompositeTextMapPropagator(), nil
+		default:
+			return nil, fmt.Errorf("unknown propagator %q", name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
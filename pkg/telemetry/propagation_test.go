@@ -0,0 +1,64 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPropagatorsFromEnv(t *testing.T) {
+	t.Setenv("OTEL_PROPAGATORS", "")
+	if names := PropagatorsFromEnv(); !slices.Equal(names, []string{"tracecontext", "baggage"}) {
+		t.Errorf("Expected the default propagators, but got %v", names)
+	}
+
+	t.Setenv("OTEL_PROPAGATORS", "tracecontext, b3multi ,jaeger")
+	if names := PropagatorsFromEnv(); !slices.Equal(names, []string{"tracecontext", "b3multi", "jaeger"}) {
+		t.Errorf("Expected tracecontext, b3multi and jaeger, but got %v", names)
+	}
+}
+
+func TestPropagatorForwardsB3AsEveryFormat(t *testing.T) {
+	p, err := NewPropagator([]string{"tracecontext", "baggage", "b3", "b3multi", "jaeger"})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	in := http.Header{}
+	in.Set("b3", "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1")
+	ctx := p.Extract(context.Background(), propagation.HeaderCarrier(in))
+
+	sc := trace.SpanContextFromContext(ctx)
+	if sc.TraceID().String() != "80f198ee56343ba864fe8b2a57d3eff7" {
+		t.Fatalf("Expected the B3 trace ID to be extracted, but got %s", sc.TraceID())
+	}
+
+	out := http.Header{}
+	p.Inject(ctx, propagation.HeaderCarrier(out))
+	for _, header := range []string{"traceparent", "b3", "X-B3-TraceId", "uber-trace-id"} {
+		if out.Get(header) == "" {
+			t.Errorf("Expected the %s header to be injected", header)
+		}
+	}
+	if out.Get("traceparent") != "00-80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-01" {
+		t.Errorf("Expected traceparent to continue the B3 trace, but got %s", out.Get("traceparent"))
+	}
+}
+
+func TestPropagatorNoneAndUnknown(t *testing.T) {
+	p, err := NewPropagator([]string{"none"})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if fields := p.Fields(); len(fields) != 0 {
+		t.Errorf("Expected none to propagate nothing, but got %v", fields)
+	}
+
+	if _, err := NewPropagator([]string{"xray"}); err == nil {
+		t.Errorf("Expected an error for an unknown propagator, but got none")
+	}
+}
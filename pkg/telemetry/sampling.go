@@ -0,0 +1,268 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxKeptTraces bounds how many unsampled traces are buffered while waiting
+// for their local root span to end.
+const maxKeptTraces = 10000
+
+// SamplingConfig selects the head sampler and the rule that keeps unsampled
+// traces which turn out to fail or to be slow.
+type SamplingConfig struct {
+	// Sampler is one of always_on, always_off, traceidratio, ratelimiting or
+	// their parentbased_ variants, as in OTEL_TRACES_SAMPLER.
+	Sampler string
+	// Arg is the ratio for traceidratio and the number of traces per second
+	// for ratelimiting, as in OTEL_TRACES_SAMPLER_ARG.
+	Arg string
+
+	// KeepErrors exports traces a ratio or rate limit dropped when one of
+	// their spans ends with an error status.
+	KeepErrors bool
+	// KeepSlowerThan exports traces a ratio or rate limit dropped when their
+	// local root span takes at least this long. Zero disables the rule.
+	KeepSlowerThan time.Duration
+}
+
+// SamplingConfigFromEnv reads OTEL_TRACES_SAMPLER, OTEL_TRACES_SAMPLER_ARG,
+// TRACES_KEEP_ERRORS and TRACES_KEEP_SLOWER_THAN. Failed traces are kept
+// unless TRACES_KEEP_ERRORS is false.
+func SamplingConfigFromEnv() (SamplingConfig, error) {
+	c := SamplingConfig{
+		Sampler:    os.Getenv("OTEL_TRACES_SAMPLER"),
+		Arg:        os.Getenv("OTEL_TRACES_SAMPLER_ARG"),
+		KeepErrors: true,
+	}
+
+	if v := os.Getenv("TRACES_KEEP_ERRORS"); v != "" {
+		keep, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid TRACES_KEEP_ERRORS: %w", err)
+		}
+		c.KeepErrors = keep
+	}
+
+	if v := os.Getenv("TRACES_KEEP_SLOWER_THAN"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid TRACES_KEEP_SLOWER_THAN: %w", err)
+		}
+		c.KeepSlowerThan = d
+	}
+
+	return c, nil
+}
+
+func (c SamplingConfig) keeps() bool {
+	return c.KeepErrors || c.KeepSlowerThan > 0
+}
+
+// sampler builds the head sampler. Without a configured sampler it defaults
+// to parentbased_always_on, as the specification does. Only the traces a
+// ratio or rate limit drops are still recorded for the keep rules: always_off
+// drops everything.
+func (c SamplingConfig) sampler() (sdktrace.Sampler, error) {
+	var s sdktrace.Sampler
+	switch c.Sampler {
+	case "", "parentbased_always_on":
+		s = sdktrace.ParentBased(sdktrace.AlwaysSample())
+	case "always_on":
+		s = sdktrace.AlwaysSample()
+	case "always_off":
+		s = sdktrace.NeverSample()
+	case "parentbased_always_off":
+		s = sdktrace.ParentBased(sdktrace.NeverSample())
+	case "traceidratio", "parentbased_traceidratio":
+		ratio := 1.0
+		if c.Arg != "" {
+			var err error
+			if ratio, err = strconv.ParseFloat(c.Arg, 64); err != nil || ratio < 0 || ratio > 1 {
+				return nil, fmt.Errorf("invalid traceidratio sampler argument %q", c.Arg)
+			}
+		}
+		s = c.recording(sdktrace.TraceIDRatioBased(ratio))
+		if c.Sampler == "parentbased_traceidratio" {
+			s = c.parentBased(s)
+		}
+	case "ratelimiting", "parentbased_ratelimiting":
+		perSecond, err := strconv.ParseFloat(c.Arg, 64)
+		if err != nil || perSecond < 0 {
+			return nil, fmt.Errorf("invalid ratelimiting sampler argument %q", c.Arg)
+		}
+		s = c.recording(newRateLimitingSampler(perSecond))
+		if c.Sampler == "parentbased_ratelimiting" {
+			s = c.parentBased(s)
+		}
+	default:
+		return nil, fmt.Errorf("unknown sampler %q", c.Sampler)
+	}
+	return s, nil
+}
+
+// recording wraps s in a recordingSampler when a keep rule is enabled.
+func (c SamplingConfig) recording(s sdktrace.Sampler) sdktrace.Sampler {
+	if c.keeps() {
+		return recordingSampler{s}
+	}
+	return s
+}
+
+// parentBased follows the decision of the parent, also recording the children
+// of a root the recordingSampler recorded when a keep rule is enabled.
+func (c SamplingConfig) parentBased(root sdktrace.Sampler) sdktrace.Sampler {
+	if c.keeps() {
+		return sdktrace.ParentBased(root, sdktrace.WithLocalParentNotSampled(recordingSampler{sdktrace.NeverSample()}))
+	}
+	return sdktrace.ParentBased(root)
+}
+
+// rateLimitingSampler samples at most perSecond new traces every second,
+// using a token bucket that holds up to one second worth of traces.
+type rateLimitingSampler struct {
+	mu        sync.Mutex
+	perSecond float64
+	tokens    float64
+	last      time.Time
+}
+
+func newRateLimitingSampler(perSecond float64) *rateLimitingSampler {
+	return &rateLimitingSampler{perSecond: perSecond, tokens: perSecond, last: time.Now()}
+}
+
+func (s *rateLimitingSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	s.mu.Lock()
+	now := time.Now()
+	s.tokens = min(s.perSecond, s.tokens+now.Sub(s.last).Seconds()*s.perSecond)
+	s.last = now
+
+	decision := sdktrace.Drop
+	if s.tokens >= 1 {
+		s.tokens--
+		decision = sdktrace.RecordAndSample
+	}
+	s.mu.Unlock()
+
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (s *rateLimitingSampler) Description() string {
+	return fmt.Sprintf("RateLimitingSampler{%g}", s.perSecond)
+}
+
+// recordingSampler turns the drop decisions of the wrapped ratio or rate
+// limit into record-only ones, for new traces and for the children of spans
+// it recorded, so keepProcessor can still export the trace if it fails or is
+// slow. Traces an upstream service dropped stay dropped.
+type recordingSampler struct {
+	sdktrace.Sampler
+}
+
+func (s recordingSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	res := s.Sampler.ShouldSample(p)
+	if res.Decision != sdktrace.Drop {
+		return res
+	}
+	parent := trace.SpanFromContext(p.ParentContext)
+	if psc := parent.SpanContext(); !psc.IsValid() || !psc.IsRemote() && parent.IsRecording() {
+		res.Decision = sdktrace.RecordOnly
+	}
+	return res
+}
+
+func (s recordingSampler) Description() string {
+	return "Recording{" + s.Sampler.Description() + "}"
+}
+
+// keepProcessor forwards sampled spans to next. Record-only spans are held
+// per trace until the local root span ends, and are then forwarded as
+// sampled only if the trace had an error or the root was slow.
+type keepProcessor struct {
+	next   sdktrace.SpanProcessor
+	config SamplingConfig
+
+	mu     sync.Mutex
+	traces map[trace.TraceID]*keptTrace
+}
+
+type keptTrace struct {
+	spans  []sdktrace.ReadOnlySpan
+	failed bool
+}
+
+func newKeepProcessor(next sdktrace.SpanProcessor, config SamplingConfig) *keepProcessor {
+	return &keepProcessor{next: next, config: config, traces: make(map[trace.TraceID]*keptTrace)}
+}
+
+func (p *keepProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+func (p *keepProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.next.OnEnd(s)
+		return
+	}
+
+	id := s.SpanContext().TraceID()
+	localRoot := !s.Parent().IsValid() || s.Parent().IsRemote()
+
+	p.mu.Lock()
+	t, ok := p.traces[id]
+	if !ok {
+		if len(p.traces) >= maxKeptTraces && !localRoot {
+			p.mu.Unlock()
+			return
+		}
+		t = &keptTrace{}
+		p.traces[id] = t
+	}
+	t.spans = append(t.spans, s)
+	t.failed = t.failed || s.Status().Code == codes.Error
+	if !localRoot {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.traces, id)
+	p.mu.Unlock()
+
+	slow := p.config.KeepSlowerThan > 0 && s.EndTime().Sub(s.StartTime()) >= p.config.KeepSlowerThan
+	if !(p.config.KeepErrors && t.failed) && !slow {
+		return
+	}
+	for _, span := range t.spans {
+		p.next.OnEnd(sampledSpan{span})
+	}
+}
+
+func (p *keepProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *keepProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// sampledSpan reports a kept record-only span as sampled, since span
+// processors only export sampled spans.
+type sampledSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s sampledSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
@@ -0,0 +1,150 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSamplerFromEnv(t *testing.T) {
+	tests := []struct {
+		sampler     string
+		arg         string
+		description string
+	}{
+		{"", "", "ParentBased{root:AlwaysOnSampler,remoteParentSampled:AlwaysOnSampler,remoteParentNotSampled:AlwaysOffSampler,localParentSampled:AlwaysOnSampler,localParentNotSampled:AlwaysOffSampler}"},
+		{"always_off", "", "AlwaysOffSampler"},
+		{"traceidratio", "0.25", "Recording{TraceIDRatioBased{0.25}}"},
+		{"ratelimiting", "10", "Recording{RateLimitingSampler{10}}"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("OTEL_TRACES_SAMPLER", tt.sampler)
+		t.Setenv("OTEL_TRACES_SAMPLER_ARG", tt.arg)
+
+		c, err := SamplingConfigFromEnv()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		s, err := c.sampler()
+		if err != nil {
+			t.Fatalf("Expected no error for %q, but got %v", tt.sampler, err)
+		}
+		if s.Description() != tt.description {
+			t.Errorf("Expected sampler %q to be %s, but got %s", tt.sampler, tt.description, s.Description())
+		}
+	}
+}
+
+func TestSamplerRejectsInvalidConfig(t *testing.T) {
+	for _, c := range []SamplingConfig{
+		{Sampler: "sometimes"},
+		{Sampler: "traceidratio", Arg: "2"},
+		{Sampler: "ratelimiting", Arg: "many"},
+	} {
+		if _, err := c.sampler(); err == nil {
+			t.Errorf("Expected an error for %+v, but got none", c)
+		}
+	}
+}
+
+func TestRateLimitingSamplerLimitsTracesPerSecond(t *testing.T) {
+	s := newRateLimitingSampler(2)
+
+	sampled := 0
+	for range 5 {
+		if s.ShouldSample(sdktrace.SamplingParameters{ParentContext: context.Background()}).Decision == sdktrace.RecordAndSample {
+			sampled++
+		}
+	}
+	if sampled != 2 {
+		t.Errorf("Expected 2 sampled traces, but got %d", sampled)
+	}
+}
+
+func newKeepTracerProvider(c SamplingConfig) (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	sampler, _ := c.sampler()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithSpanProcessor(newKeepProcessor(sdktrace.NewSimpleSpanProcessor(exporter), c)),
+	)
+	return tp, exporter
+}
+
+func TestKeepProcessorExportsFailedTraces(t *testing.T) {
+	tp, exporter := newKeepTracerProvider(SamplingConfig{Sampler: "parentbased_traceidratio", Arg: "0", KeepErrors: true})
+	tracer := tp.Tracer("test")
+
+	ctx, root := tracer.Start(context.Background(), "RootSpan")
+	_, child := tracer.Start(ctx, "ChildSpan")
+	child.SetStatus(codes.Error, "upstream failed")
+	child.End()
+	root.End()
+
+	_, ok := tracer.Start(context.Background(), "OkSpan")
+	ok.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected the 2 spans of the failed trace, but got %d", len(spans))
+	}
+	for _, s := range spans {
+		if !s.SpanContext.IsSampled() {
+			t.Errorf("Expected kept span %s to be exported as sampled", s.Name)
+		}
+	}
+}
+
+func TestKeepProcessorExportsSlowTraces(t *testing.T) {
+	tp, exporter := newKeepTracerProvider(SamplingConfig{Sampler: "traceidratio", Arg: "0", KeepSlowerThan: time.Second})
+	tracer := tp.Tracer("test")
+
+	start := time.Now()
+	_, slow := tracer.Start(context.Background(), "SlowSpan", trace.WithTimestamp(start))
+	slow.End(trace.WithTimestamp(start.Add(2 * time.Second)))
+
+	_, fast := tracer.Start(context.Background(), "FastSpan", trace.WithTimestamp(start))
+	fast.End(trace.WithTimestamp(start.Add(time.Millisecond)))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "SlowSpan" {
+		t.Errorf("Expected only SlowSpan to be exported, but got %v", spans.Snapshots())
+	}
+}
+
+func TestKeepProcessorLeavesAlwaysOffAlone(t *testing.T) {
+	tp, exporter := newKeepTracerProvider(SamplingConfig{Sampler: "always_off", KeepErrors: true})
+	tracer := tp.Tracer("test")
+
+	_, span := tracer.Start(context.Background(), "FailedSpan")
+	if span.IsRecording() {
+		t.Errorf("Expected always_off not to record spans")
+	}
+	span.SetStatus(codes.Error, "upstream failed")
+	span.End()
+
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("Expected always_off to drop every trace, but got %v", spans.Snapshots())
+	}
+}
+
+func TestSamplingConfigFromEnvKeepsErrorsByDefault(t *testing.T) {
+	c, err := SamplingConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if !c.KeepErrors {
+		t.Errorf("Expected failed traces to be kept by default")
+	}
+
+	t.Setenv("TRACES_KEEP_ERRORS", "false")
+	if c, _ = SamplingConfigFromEnv(); c.KeepErrors {
+		t.Errorf("Expected TRACES_KEEP_ERRORS=false to turn the rule off")
+	}
+}
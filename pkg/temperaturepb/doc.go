@@ -0,0 +1,5 @@
+// Package temperaturepb holds the protobuf messages and gRPC stubs of the
+// TemperatureService served by ServiceB and called by ServiceA.
+//
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative temperature.proto
+package temperaturepb
@@ -0,0 +1,225 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: temperature.proto
+
+package temperaturepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetTemperatureRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The 8-digit CEP, without punctuation.
+	Cep           string `protobuf:"bytes,1,opt,name=cep,proto3" json:"cep,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTemperatureRequest) Reset() {
+	*x = GetTemperatureRequest{}
+	mi := &file_temperature_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTemperatureRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTemperatureRequest) ProtoMessage() {}
+
+func (x *GetTemperatureRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_temperature_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTemperatureRequest.ProtoReflect.Descriptor instead.
+func (*GetTemperatureRequest) Descriptor() ([]byte, []int) {
+	return file_temperature_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetTemperatureRequest) GetCep() string {
+	if x != nil {
+		return x.Cep
+	}
+	return ""
+}
+
+type GetTemperatureResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	City  string                 `protobuf:"bytes,1,opt,name=city,proto3" json:"city,omitempty"`
+	TempC float64                `protobuf:"fixed64,2,opt,name=temp_c,json=tempC,proto3" json:"temp_c,omitempty"`
+	TempF float64                `protobuf:"fixed64,3,opt,name=temp_f,json=tempF,proto3" json:"temp_f,omitempty"`
+	TempK float64                `protobuf:"fixed64,4,opt,name=temp_k,json=tempK,proto3" json:"temp_k,omitempty"`
+	// When the upstream measured the temperature.
+	ObservedAt *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=observed_at,json=observedAt,proto3" json:"observed_at,omitempty"`
+	// How many seconds before the answer the temperature was measured.
+	Age           int64 `protobuf:"varint,6,opt,name=age,proto3" json:"age,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTemperatureResponse) Reset() {
+	*x = GetTemperatureResponse{}
+	mi := &file_temperature_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTemperatureResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTemperatureResponse) ProtoMessage() {}
+
+func (x *GetTemperatureResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_temperature_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTemperatureResponse.ProtoReflect.Descriptor instead.
+func (*GetTemperatureResponse) Descriptor() ([]byte, []int) {
+	return file_temperature_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetTemperatureResponse) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *GetTemperatureResponse) GetTempC() float64 {
+	if x != nil {
+		return x.TempC
+	}
+	return 0
+}
+
+func (x *GetTemperatureResponse) GetTempF() float64 {
+	if x != nil {
+		return x.TempF
+	}
+	return 0
+}
+
+func (x *GetTemperatureResponse) GetTempK() float64 {
+	if x != nil {
+		return x.TempK
+	}
+	return 0
+}
+
+func (x *GetTemperatureResponse) GetObservedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ObservedAt
+	}
+	return nil
+}
+
+func (x *GetTemperatureResponse) GetAge() int64 {
+	if x != nil {
+		return x.Age
+	}
+	return 0
+}
+
+var File_temperature_proto protoreflect.FileDescriptor
+
+const file_temperature_proto_rawDesc = "" +
+	"\n" +
+	"\x11temperature.proto\x12\x0etemperature.v1\x1a\x1fgoogle/protobuf/timestamp.proto\")\n" +
+	"\x15GetTemperatureRequest\x12\x10\n" +
+	"\x03cep\x18\x01 \x01(\tR\x03cep\"\xc0\x01\n" +
+	"\x16GetTemperatureResponse\x12\x12\n" +
+	"\x04city\x18\x01 \x01(\tR\x04city\x12\x15\n" +
+	"\x06temp_c\x18\x02 \x01(\x01R\x05tempC\x12\x15\n" +
+	"\x06temp_f\x18\x03 \x01(\x01R\x05tempF\x12\x15\n" +
+	"\x06temp_k\x18\x04 \x01(\x01R\x05tempK\x12;\n" +
+	"\vobserved_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"observedAt\x12\x10\n" +
+	"\x03age\x18\x06 \x01(\x03R\x03age2u\n" +
+	"\x12TemperatureService\x12_\n" +
+	"\x0eGetTemperature\x12%.temperature.v1.GetTemperatureRequest\x1a&.temperature.v1.GetTemperatureResponseB<Z:github.com/EnnioSimoes/2-Observabilidade/pkg/temperaturepbb\x06proto3"
+
+var (
+	file_temperature_proto_rawDescOnce sync.Once
+	file_temperature_proto_rawDescData []byte
+)
+
+func file_temperature_proto_rawDescGZIP() []byte {
+	file_temperature_proto_rawDescOnce.Do(func() {
+		file_temperature_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_temperature_proto_rawDesc), len(file_temperature_proto_rawDesc)))
+	})
+	return file_temperature_proto_rawDescData
+}
+
+var file_temperature_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_temperature_proto_goTypes = []any{
+	(*GetTemperatureRequest)(nil),  // 0: temperature.v1.GetTemperatureRequest
+	(*GetTemperatureResponse)(nil), // 1: temperature.v1.GetTemperatureResponse
+	(*timestamppb.Timestamp)(nil),  // 2: google.protobuf.Timestamp
+}
+var file_temperature_proto_depIdxs = []int32{
+	2, // 0: temperature.v1.GetTemperatureResponse.observed_at:type_name -> google.protobuf.Timestamp
+	0, // 1: temperature.v1.TemperatureService.GetTemperature:input_type -> temperature.v1.GetTemperatureRequest
+	1, // 2: temperature.v1.TemperatureService.GetTemperature:output_type -> temperature.v1.GetTemperatureResponse
+	2, // [2:3] is the sub-list for method output_type
+	1, // [1:2] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_temperature_proto_init() }
+func file_temperature_proto_init() {
+	if File_temperature_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_temperature_proto_rawDesc), len(file_temperature_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_temperature_proto_goTypes,
+		DependencyIndexes: file_temperature_proto_depIdxs,
+		MessageInfos:      file_temperature_proto_msgTypes,
+	}.Build()
+	File_temperature_proto = out.File
+	file_temperature_proto_goTypes = nil
+	file_temperature_proto_depIdxs = nil
+}